@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/hajimehoshi/go2cpp/gowasm2cpp"
+)
+
+type builder struct {
+	cxx       string
+	cxxflags  []string
+	linkflags []string
+	cachedir  string
+	verbose   bool
+}
+
+func defaultCXX() string {
+	if cxx := os.Getenv("CXX"); cxx != "" {
+		return cxx
+	}
+	return "clang++"
+}
+
+func runBuild(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gowasm2cpp build [flags] package [C++ sources]")
+		fs.PrintDefaults()
+	}
+	var (
+		flagOut       = fs.String("o", "", "Output binary (default: the last element of the package path)")
+		flagAutogen   = fs.String("autogen", "autogen", "Directory for the generated C++ files")
+		flagNamespace = fs.String("namespace", "go2cpp_autogen", "Namespace")
+		flagTags      = fs.String("tags", "", "Go build tags")
+		flagLDFlags   = fs.String("ldflags", "", "Flags for the Go linker")
+		flagGOOS      = fs.String("goos", "js", "GOOS (js or wasip1)")
+		flagTest      = fs.Bool("test", false, "Build the test binary of the package (go test -c)")
+		flagCXX       = fs.String("cxx", defaultCXX(), "C++ compiler")
+		flagCXXFlags  = fs.String("cxxflags", "-Wall -std=c++14 -pthread -g", "Flags for compiling C++ files")
+		flagLinkFlags = fs.String("linkflags", "", "Additional flags for linking")
+		flagLibs      = fs.String("libs", "", "Space-separated libraries to link (e.g. \"glfw GL\")")
+		flagCache     = fs.String("cache", ".cache", "Directory for cached object files")
+		flagPartition = fs.String("partition", string(gowasm2cpp.PartitionIndex), "Strategy to split functions into files (index or package)")
+		flagGroupSize = fs.Int("group-size", 64, "Number of functions in one file")
+		flagUnity     = fs.Int("unity", 0, "Number of amalgamated C++ files for unity builds (0: disabled)")
+		flagConfig    = fs.String("config", "", "Configuration file (default: go2cpp.json if exists)")
+		flagSnippets  = fs.String("snippets", "", "Directory of C++ snippets of function bodies")
+		flagKeepGoing = fs.Bool("keep-going", false, "Replace functions that cannot be converted with aborting stubs and report them at the end")
+		flagVerbose   = fs.Bool("v", false, "Print the commands")
+
+		flagKeepUnreachable = fs.Bool("keep-unreachable", false, "Generate the functions unreachable from the exports and the tables")
+	)
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	pkg := fs.Arg(0)
+	srcs := fs.Args()[1:]
+
+	out := *flagOut
+	if out == "" {
+		p := path.Base(pkg)
+		if p == "." {
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			p = filepath.Base(wd)
+		}
+		out = p
+	}
+
+	tmp, err := ioutil.TempDir("", "gowasm2cpp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	wasm := filepath.Join(tmp, "main.wasm")
+	gb := &goBuild{
+		pkg:     pkg,
+		tags:    *flagTags,
+		ldflags: *flagLDFlags,
+		goos:    *flagGOOS,
+		test:    *flagTest,
+	}
+	if err := gb.build(wasm); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*flagConfig)
+	if err != nil {
+		return err
+	}
+	overrideString(fs, "autogen", *flagAutogen, &cfg.OutDir)
+	if isFlagSet(fs, "autogen") || cfg.Include == "" {
+		cfg.Include = cfg.OutDir
+	}
+	overrideString(fs, "namespace", *flagNamespace, &cfg.Namespace)
+	partition := string(cfg.Partition)
+	overrideString(fs, "partition", *flagPartition, &partition)
+	cfg.Partition = gowasm2cpp.Partition(partition)
+	overrideInt(fs, "group-size", *flagGroupSize, &cfg.GroupSize)
+	overrideInt(fs, "unity", *flagUnity, &cfg.Unity)
+	overrideBool(fs, "keep-going", *flagKeepGoing, &cfg.KeepGoing)
+	overrideBool(fs, "keep-unreachable", *flagKeepUnreachable, &cfg.KeepUnreachable)
+	overrideString(fs, "snippets", *flagSnippets, &cfg.SnippetsDir)
+
+	cfg.Wasm = wasm
+
+	// In the keep-going mode, the unconverted functions are reported after building the binary.
+	var funcErrs gowasm2cpp.FuncErrors
+	if err := gowasm2cpp.GenerateWithConfig(context.Background(), *cfg); err != nil {
+		if !errors.As(err, &funcErrs) {
+			return err
+		}
+	}
+
+	mb, err := ioutil.ReadFile(filepath.Join(cfg.OutDir, gowasm2cpp.ManifestFileName))
+	if err != nil {
+		return err
+	}
+	var manifest gowasm2cpp.Manifest
+	if err := json.Unmarshal(mb, &manifest); err != nil {
+		return err
+	}
+	if *flagVerbose && manifest.RemovedFuncs > 0 {
+		fmt.Printf("removed %d unreachable functions (%d bytes of Wasm code)\n", manifest.RemovedFuncs, manifest.RemovedCodeSize)
+	}
+	for _, src := range manifest.Sources {
+		srcs = append(srcs, filepath.Join(cfg.OutDir, src))
+	}
+
+	b := &builder{
+		cxx:       *flagCXX,
+		cxxflags:  strings.Fields(*flagCXXFlags),
+		linkflags: strings.Fields(*flagLinkFlags),
+		cachedir:  *flagCache,
+		verbose:   *flagVerbose,
+	}
+	for _, l := range strings.Fields(*flagLibs) {
+		b.linkflags = append(b.linkflags, "-l"+l)
+	}
+
+	objs, err := b.compile(srcs)
+	if err != nil {
+		return err
+	}
+	if err := b.link(out, objs); err != nil {
+		return err
+	}
+	if funcErrs != nil {
+		return funcErrs
+	}
+	return nil
+}
+
+// compile compiles the C++ sources and returns the object files.
+//
+// The object files are cached in the cache directory. The key is the hash of the compiler, the preprocessed source and
+// the flags.
+func (b *builder) compile(srcs []string) ([]string, error) {
+	if err := os.MkdirAll(b.cachedir, 0755); err != nil {
+		return nil, err
+	}
+	id, err := b.compilerID()
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]string, len(srcs))
+	var g errgroup.Group
+	for i, src := range srcs {
+		i, src := i, src
+		g.Go(func() error {
+			ppargs := append([]string{"-E"}, b.cxxflags...)
+			ppargs = append(ppargs, "-I.", src)
+			ppcmd := exec.Command(b.cxx, ppargs...)
+			ppcmd.Stderr = os.Stderr
+			pp, err := ppcmd.Output()
+			if err != nil {
+				return err
+			}
+
+			h := sha256.New()
+			h.Write(id)
+			h.Write(pp)
+			h.Write([]byte(strings.Join(b.cxxflags, "\x00")))
+			obj := filepath.Join(b.cachedir, hex.EncodeToString(h.Sum(nil))+".o")
+			objs[i] = obj
+
+			if _, err := os.Stat(obj); err == nil {
+				return nil
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+
+			// Compile to a temporary file first so that a broken object file is never cached.
+			tmp := fmt.Sprintf("%s.%d.tmp", obj, i)
+			args := append([]string{}, b.cxxflags...)
+			args = append(args, "-I.", "-c", "-o", tmp, src)
+			if err := b.run(args); err != nil {
+				return err
+			}
+			return os.Rename(tmp, obj)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// compilerID returns the identity of the compiler: the resolved path and the output of --version.
+// The identity changes when another compiler is specified or the compiler is upgraded.
+func (b *builder) compilerID() ([]byte, error) {
+	p, err := exec.LookPath(b.cxx)
+	if err != nil {
+		return nil, err
+	}
+	// A compiler like c++ is often a symbolic link to the actual compiler.
+	if r, err := filepath.EvalSymlinks(p); err == nil {
+		p = r
+	}
+	if a, err := filepath.Abs(p); err == nil {
+		p = a
+	}
+	cmd := exec.Command(b.cxx, "--version")
+	cmd.Stderr = os.Stderr
+	v, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gowasm2cpp: %s --version failed: %v", b.cxx, err)
+	}
+	return append([]byte(p+"\x00"), v...), nil
+}
+
+func (b *builder) link(out string, objs []string) error {
+	args := append([]string{}, b.cxxflags...)
+	args = append(args, "-o", out)
+	args = append(args, objs...)
+	args = append(args, b.linkflags...)
+	return b.run(args)
+}
+
+func (b *builder) run(args []string) error {
+	if b.verbose {
+		fmt.Println(b.cxx, strings.Join(args, " "))
+	}
+	cmd := exec.Command(b.cxx, args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/go2cpp/gowasm2cpp"
+)
+
+// loadConfig loads the configuration file at path.
+// If path is empty, go2cpp.json in the current directory is loaded if it exists.
+func loadConfig(path string) (*gowasm2cpp.Config, error) {
+	if path == "" {
+		if _, err := os.Stat(gowasm2cpp.ConfigFileName); err != nil {
+			if os.IsNotExist(err) {
+				return &gowasm2cpp.Config{}, nil
+			}
+			return nil, err
+		}
+		path = gowasm2cpp.ConfigFileName
+	}
+	return gowasm2cpp.LoadConfig(path)
+}
+
+// isFlagSet reports whether the flag name is explicitly specified in the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	var found bool
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// overrideString sets flagValue to v if the flag is specified or v is empty.
+func overrideString(fs *flag.FlagSet, name string, flagValue string, v *string) {
+	if isFlagSet(fs, name) || *v == "" {
+		*v = flagValue
+	}
+}
+
+// overrideInt sets flagValue to v if the flag is specified or v is 0.
+func overrideInt(fs *flag.FlagSet, name string, flagValue int, v *int) {
+	if isFlagSet(fs, name) || *v == 0 {
+		*v = flagValue
+	}
+}
+
+// overrideInt64 sets flagValue to v if the flag is specified or v is 0.
+func overrideInt64(fs *flag.FlagSet, name string, flagValue int64, v *int64) {
+	if isFlagSet(fs, name) || *v == 0 {
+		*v = flagValue
+	}
+}
+
+// overrideBool sets flagValue to v if the flag is specified.
+func overrideBool(fs *flag.FlagSet, name string, flagValue bool, v *bool) {
+	if isFlagSet(fs, name) {
+		*v = flagValue
+	}
+}
+
+// overrideSubsystems sets the comma-separated subsystems in flagValue to v if the flag is specified.
+func overrideSubsystems(fs *flag.FlagSet, name string, flagValue string, v *[]gowasm2cpp.Subsystem) {
+	if !isFlagSet(fs, name) {
+		return
+	}
+	*v = []gowasm2cpp.Subsystem{}
+	for _, s := range strings.Split(flagValue, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		*v = append(*v, gowasm2cpp.Subsystem(s))
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// goBuild represents an invocation of the Go toolchain to build a Wasm file.
+type goBuild struct {
+	pkg     string
+	tags    string
+	ldflags string
+
+	// goos is GOOS for GOARCH=wasm, i.e., js or wasip1. If goos is empty, js is used.
+	goos string
+
+	// test indicates whether to build a test binary by `go test -c`.
+	test bool
+}
+
+// build builds the Go package for wasm and writes the result to out.
+func (b *goBuild) build(out string) error {
+	var args []string
+	if b.test {
+		args = append(args, "test", "-c")
+	} else {
+		args = append(args, "build")
+	}
+	args = append(args, "-trimpath", "-o", out)
+	if b.tags != "" {
+		args = append(args, "-tags", b.tags)
+	}
+	if b.ldflags != "" {
+		args = append(args, "-ldflags", b.ldflags)
+	}
+	args = append(args, b.pkg)
+
+	goos := b.goos
+	if goos == "" {
+		goos = "js"
+	}
+	cmd := exec.Command("go", args...)
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=wasm")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
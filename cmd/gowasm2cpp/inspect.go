@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/go2cpp/gowasm2cpp"
+)
+
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gowasm2cpp inspect [flags] [Wasm file]")
+		fs.PrintDefaults()
+	}
+	var (
+		flagJSON     = fs.Bool("json", false, "Print the report in JSON")
+		flagTop      = fs.Int("top", gowasm2cpp.DefaultReportBiggestFuncs, "Number of the biggest functions to report")
+		flagPkg      = fs.String("pkg", "", "Go package to build with the Go toolchain instead of the Wasm file")
+		flagTags     = fs.String("tags", "", "Go build tags for -pkg")
+		flagLDFlags  = fs.String("ldflags", "", "Flags for the Go linker for -pkg")
+		flagGOOS     = fs.String("goos", "js", "GOOS for -pkg (js or wasip1)")
+		flagTest     = fs.Bool("test", false, "Build the test binary of -pkg (go test -c)")
+		flagConfig   = fs.String("config", "", "Configuration file (default: go2cpp.json if exists)")
+		flagSnippets = fs.String("snippets", "", "Directory of C++ snippets of function bodies")
+
+		flagKeepUnreachable = fs.Bool("keep-unreachable", false, "Inspect the functions unreachable from the exports and the tables")
+	)
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*flagConfig)
+	if err != nil {
+		return err
+	}
+
+	overrideString(fs, "snippets", *flagSnippets, &cfg.SnippetsDir)
+	overrideBool(fs, "keep-unreachable", *flagKeepUnreachable, &cfg.KeepUnreachable)
+
+	wasm := cfg.Wasm
+	if fs.NArg() > 0 {
+		wasm = fs.Arg(0)
+	}
+	if *flagPkg != "" {
+		tmp, err := ioutil.TempDir("", "gowasm2cpp-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+
+		wasm = filepath.Join(tmp, "main.wasm")
+		gb := &goBuild{
+			pkg:     *flagPkg,
+			tags:    *flagTags,
+			ldflags: *flagLDFlags,
+			goos:    *flagGOOS,
+			test:    *flagTest,
+		}
+		if err := gb.build(wasm); err != nil {
+			return err
+		}
+	}
+	if wasm == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	r, err := gowasm2cpp.Inspect(wasm, &cfg.Options, *flagTop)
+	if err != nil {
+		return err
+	}
+
+	if *flagJSON {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		if _, err := os.Stdout.Write(b); err != nil {
+			return err
+		}
+	} else {
+		if err := r.WriteText(os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if !r.OK() {
+		return fmt.Errorf("%d imports without implementations and %d unsupported functions", len(r.MissingImports), len(r.UnsupportedFuncs))
+	}
+	return nil
+}
@@ -3,9 +3,13 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/pkg/profile"
 
@@ -15,21 +19,118 @@ import (
 var (
 	flagOut       = flag.String("out", ".", "Output directory")
 	flagInclude   = flag.String("include", "", "Include path")
-	flagWasm      = flag.String("wasm", "", "WebAssembly file generated by Go")
+	flagWasm      = flag.String("wasm", "", "WebAssembly file generated by Go. With -pkg, the built WebAssembly file is kept at this path")
+	flagPkg       = flag.String("pkg", "", "Go package to build with the Go toolchain instead of -wasm")
+	flagTags      = flag.String("tags", "", "Go build tags for -pkg")
+	flagLDFlags   = flag.String("ldflags", "", "Flags for the Go linker for -pkg")
+	flagGOOS      = flag.String("goos", "js", "GOOS for -pkg (js or wasip1)")
+	flagTest      = flag.Bool("test", false, "Build the test binary of -pkg (go test -c)")
 	flagNamespace = flag.String("namespace", "", "Namespace")
 	flagProfile   = flag.Bool("profile", false, "Take profiles")
+	flagPartition = flag.String("partition", string(gowasm2cpp.PartitionIndex), "Strategy to split functions into files (index or package)")
+	flagGroupSize = flag.Int("group-size", 64, "Number of functions in one file")
+	flagEmitCMake = flag.Bool("emit-cmake", false, "Generate CMakeLists.txt")
+	flagEmitNinja = flag.Bool("emit-ninja", false, "Generate build.ninja")
+	flagUnity     = flag.Int("unity", 0, "Number of amalgamated C++ files for unity builds (0: disabled)")
+	flagConfig    = flag.String("config", "", "Configuration file (default: go2cpp.json if exists)")
+	flagSnippets  = flag.String("snippets", "", "Directory of C++ snippets of function bodies")
+	flagKeepGoing = flag.Bool("keep-going", false, "Replace functions that cannot be converted with aborting stubs and report them at the end")
+
+	flagKeepUnreachable = flag.Bool("keep-unreachable", false, "Generate the functions unreachable from the exports and the tables")
+
+	flagSubsystems     = flag.String("subsystems", "gl", "Comma-separated optional runtime subsystems")
+	flagReservedMemory = flag.Int64("reserved-memory", 0, "Bytes reserved for the linear memory at start (0: 1GiB)")
+	flagMaxMemory      = flag.Int64("max-memory", 0, "Maximum bytes of the linear memory (0: 4GiB)")
+
+	flagRuntimeOnly      = flag.Bool("runtime-only", false, "Generate only the runtime shared by programs (no Wasm file is needed)")
+	flagProgramOnly      = flag.Bool("program-only", false, "Generate only the program that refers to the runtime generated with -runtime-only")
+	flagRuntimeNamespace = flag.String("runtime-namespace", "", "Namespace of the runtime (default: -namespace)")
+	flagRuntimeInclude   = flag.String("runtime-include", "", "Include path of the runtime for -program-only (default: -include)")
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			if err := runBuild(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "inspect":
+			if err := runInspect(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 	if *flagProfile {
 		defer profile.Start().Stop()
 	}
 
-	if err := os.MkdirAll(*flagOut, 0755); err != nil {
+	if err := generate(); err != nil {
 		log.Fatal(err)
 	}
-	if err := gowasm2cpp.Generate(*flagOut, *flagInclude, *flagWasm, *flagNamespace); err != nil {
-		log.Fatal(err)
+}
+
+func generate() error {
+	cfg, err := loadConfig(*flagConfig)
+	if err != nil {
+		return err
+	}
+	fs := flag.CommandLine
+	overrideString(fs, "out", *flagOut, &cfg.OutDir)
+	overrideString(fs, "include", *flagInclude, &cfg.Include)
+	overrideString(fs, "wasm", *flagWasm, &cfg.Wasm)
+	overrideString(fs, "namespace", *flagNamespace, &cfg.Namespace)
+	partition := string(cfg.Partition)
+	overrideString(fs, "partition", *flagPartition, &partition)
+	cfg.Partition = gowasm2cpp.Partition(partition)
+	overrideInt(fs, "group-size", *flagGroupSize, &cfg.GroupSize)
+	overrideBool(fs, "emit-cmake", *flagEmitCMake, &cfg.EmitCMake)
+	overrideBool(fs, "emit-ninja", *flagEmitNinja, &cfg.EmitNinja)
+	overrideInt(fs, "unity", *flagUnity, &cfg.Unity)
+	overrideSubsystems(fs, "subsystems", *flagSubsystems, &cfg.Subsystems)
+	overrideInt64(fs, "reserved-memory", *flagReservedMemory, &cfg.ReservedMemory)
+	overrideInt64(fs, "max-memory", *flagMaxMemory, &cfg.MaxMemory)
+	overrideBool(fs, "keep-going", *flagKeepGoing, &cfg.KeepGoing)
+	overrideBool(fs, "keep-unreachable", *flagKeepUnreachable, &cfg.KeepUnreachable)
+	overrideString(fs, "snippets", *flagSnippets, &cfg.SnippetsDir)
+	overrideBool(fs, "runtime-only", *flagRuntimeOnly, &cfg.RuntimeOnly)
+	overrideBool(fs, "program-only", *flagProgramOnly, &cfg.ProgramOnly)
+	overrideString(fs, "runtime-namespace", *flagRuntimeNamespace, &cfg.RuntimeNamespace)
+	overrideString(fs, "runtime-include", *flagRuntimeInclude, &cfg.RuntimeInclude)
+
+	if cfg.RuntimeOnly {
+		return gowasm2cpp.GenerateWithConfig(context.Background(), *cfg)
 	}
+
+	wasm := cfg.Wasm
+	if *flagPkg != "" {
+		if wasm == "" {
+			tmp, err := ioutil.TempDir("", "gowasm2cpp-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmp)
+			wasm = filepath.Join(tmp, "main.wasm")
+		}
+		gb := &goBuild{
+			pkg:     *flagPkg,
+			tags:    *flagTags,
+			ldflags: *flagLDFlags,
+			goos:    *flagGOOS,
+			test:    *flagTest,
+		}
+		if err := gb.build(wasm); err != nil {
+			return err
+		}
+	}
+	if wasm == "" {
+		return fmt.Errorf("either -wasm or -pkg must be specified")
+	}
+
+	cfg.Wasm = wasm
+	return gowasm2cpp.GenerateWithConfig(context.Background(), *cfg)
 }
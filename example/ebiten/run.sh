@@ -1,6 +1,6 @@
 set -e
-env GOOS=js GOARCH=wasm go build -tags example -o ebiten.wasm -trimpath -tags=example github.com/hajimehoshi/go-inovation
-rm -rf autogen
-go run ../../cmd/gowasm2cpp -out autogen -include autogen -wasm ebiten.wasm -namespace go2cpp_autogen
-go run build.go
+go run ../../cmd/gowasm2cpp build -v -tags example -o ebiten \
+  -cxxflags "-Wall -std=c++14 -pthread -g -DGL_SILENCE_DEPRECATION" \
+  -linkflags "-framework OpenGL" -libs glfw \
+  github.com/hajimehoshi/go-inovation *.cpp
 ./ebiten
@@ -3,7 +3,6 @@ module github.com/hajimehoshi/go2cpp
 go 1.13
 
 require (
-	github.com/go-interpreter/wagon v0.6.1-0.20200226200811-4ca95707c808
 	github.com/hajimehoshi/ebiten/v2 v2.1.0-alpha.6.0.20201221140112-a6ade8f5cd3a // indirect
 	github.com/hajimehoshi/go-inovation v0.0.0-20201221094410-67d53f8aff15
 	github.com/pkg/profile v1.4.0
@@ -3,47 +3,29 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
 )
 
-func writeBits(dir string, incpath string, namespace string) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "bits.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := bitsHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_BITS_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeBits(out *output, incpath string, namespace string) error {
+	if err := out.writeFile("bits.h", bitsHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+	}{
+		IncludeGuard: includeGuard(namespace) + "_BITS_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "bits.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := bitsCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("bits.cpp", bitsCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
@@ -69,6 +51,13 @@ public:
   static uint32_t RotateLeft(uint32_t x, int32_t k);
   static uint64_t RotateLeft(uint64_t x, int32_t k);
 
+  // The conversions between floating point numbers and their IEEE 754 bits.
+  // NaN payloads are kept.
+  static int32_t Float32ToBits(float x);
+  static int64_t Float64ToBits(double x);
+  static float Float32FromBits(int32_t x);
+  static double Float64FromBits(int64_t x);
+
 private:
   static int32_t Len(uint32_t x);
   static int32_t Len(uint64_t x);
@@ -78,6 +67,14 @@ class Math {
 public:
   static float Round(float x);
   static double Round(double x);
+
+  // The saturating conversions to integers. NaN is converted to 0, and an out-of-range value is clamped.
+  // The unsigned results are returned as the signed integers of the same bits.
+  // A float argument is promoted to double exactly.
+  static int32_t TruncSatInt32(double x);
+  static int32_t TruncSatUint32(double x);
+  static int64_t TruncSatInt64(double x);
+  static int64_t TruncSatUint64(double x);
 };
 
 }
@@ -89,6 +86,9 @@ var bitsCppTmpl = template.Must(template.New("bits.cpp").Parse(`// Code generate
 
 #include "{{.IncludePath}}bits.h"
 
+#include <cstring>
+#include <limits>
+
 namespace {
 
 const uint8_t pop8tab[] = {
@@ -204,6 +204,34 @@ uint64_t Bits::RotateLeft(uint64_t x, int32_t k) {
   return x<<s | x>>(n-s);
 }
 
+// std::memcpy is used since type punning via pointers or unions is undefined behavior in C++.
+
+int32_t Bits::Float32ToBits(float x) {
+  static_assert(sizeof(float) == sizeof(int32_t), "float must be 32 bits");
+  int32_t r;
+  std::memcpy(&r, &x, sizeof(r));
+  return r;
+}
+
+int64_t Bits::Float64ToBits(double x) {
+  static_assert(sizeof(double) == sizeof(int64_t), "double must be 64 bits");
+  int64_t r;
+  std::memcpy(&r, &x, sizeof(r));
+  return r;
+}
+
+float Bits::Float32FromBits(int32_t x) {
+  float r;
+  std::memcpy(&r, &x, sizeof(r));
+  return r;
+}
+
+double Bits::Float64FromBits(int64_t x) {
+  double r;
+  std::memcpy(&r, &x, sizeof(r));
+  return r;
+}
+
 int32_t Bits::Len(uint32_t x) {
   int32_t n = 0;
   if (x >= 1<<16) {
@@ -251,5 +279,53 @@ double Math::Round(double x) {
   return r;
 }
 
+// A static_cast from a floating point number to an integer is undefined unless the truncated value fits.
+
+int32_t Math::TruncSatInt32(double x) {
+  if (std::isnan(x)) {
+    return 0;
+  }
+  if (x <= -2147483649.0) {
+    return std::numeric_limits<int32_t>::min();
+  }
+  if (x >= 2147483648.0) {
+    return std::numeric_limits<int32_t>::max();
+  }
+  return static_cast<int32_t>(x);
+}
+
+int32_t Math::TruncSatUint32(double x) {
+  if (std::isnan(x) || x <= -1.0) {
+    return 0;
+  }
+  if (x >= 4294967296.0) {
+    return static_cast<int32_t>(std::numeric_limits<uint32_t>::max());
+  }
+  return static_cast<int32_t>(static_cast<uint32_t>(x));
+}
+
+int64_t Math::TruncSatInt64(double x) {
+  if (std::isnan(x)) {
+    return 0;
+  }
+  if (x < -9223372036854775808.0) {
+    return std::numeric_limits<int64_t>::min();
+  }
+  if (x >= 9223372036854775808.0) {
+    return std::numeric_limits<int64_t>::max();
+  }
+  return static_cast<int64_t>(x);
+}
+
+int64_t Math::TruncSatUint64(double x) {
+  if (std::isnan(x) || x <= -1.0) {
+    return 0;
+  }
+  if (x >= 18446744073709551616.0) {
+    return static_cast<int64_t>(std::numeric_limits<uint64_t>::max());
+  }
+  return static_cast<int64_t>(static_cast<uint64_t>(x));
+}
+
 }
 `))
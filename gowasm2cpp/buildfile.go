@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"path"
+	"strings"
+	"text/template"
+)
+
+// glSources are the C++ files that require OpenGL.
+var glSources = map[string]struct{}{
+	"gl.cpp":   {},
+	"game.cpp": {},
+}
+
+type buildFileParams struct {
+	Target      string
+	IncludeRoot string
+	Sources     []string
+	GLSources   []string
+}
+
+func newBuildFileParams(manifest *Manifest) *buildFileParams {
+	p := &buildFileParams{
+		Target:      manifest.Namespace,
+		IncludeRoot: includeRoot(manifest.IncludePath),
+	}
+	if p.Target == "" {
+		p.Target = "go2cpp"
+	}
+	for _, src := range manifest.Sources {
+		if _, ok := glSources[src]; ok {
+			p.GLSources = append(p.GLSources, src)
+			continue
+		}
+		p.Sources = append(p.Sources, src)
+	}
+	return p
+}
+
+// includeRoot returns the relative path from the output directory to the directory to be added to the include paths.
+// For example, if the include path is "autogen/", the root is "..".
+//
+// includeRoot returns an empty string if the include path is absolute or goes up the directories.
+func includeRoot(incpath string) string {
+	if incpath == "" {
+		return "."
+	}
+	if path.IsAbs(incpath) {
+		return ""
+	}
+	var ups []string
+	for _, e := range strings.Split(path.Clean(incpath), "/") {
+		if e == ".." {
+			return ""
+		}
+		if e == "." {
+			continue
+		}
+		ups = append(ups, "..")
+	}
+	if len(ups) == 0 {
+		return "."
+	}
+	return strings.Join(ups, "/")
+}
+
+func writeCMake(out *output, manifest *Manifest) error {
+	return out.writeFile("CMakeLists.txt", cmakeTmpl, newBuildFileParams(manifest))
+}
+
+func writeNinja(out *output, manifest *Manifest) error {
+	return out.writeFile("build.ninja", ninjaTmpl, newBuildFileParams(manifest))
+}
+
+var cmakeTmpl = template.Must(template.New("CMakeLists.txt").Parse(`# Code generated by go2cpp. DO NOT EDIT.
+
+cmake_minimum_required(VERSION 3.5)
+
+find_package(Threads REQUIRED)
+
+add_library({{.Target}} STATIC
+{{range .Sources}}  ${CMAKE_CURRENT_SOURCE_DIR}/{{.}}
+{{end}})
+set_target_properties({{.Target}} PROPERTIES
+  CXX_STANDARD 14
+  CXX_STANDARD_REQUIRED ON)
+{{if .IncludeRoot}}target_include_directories({{.Target}} PUBLIC ${CMAKE_CURRENT_SOURCE_DIR}/{{.IncludeRoot}})
+{{end}}target_link_libraries({{.Target}} PUBLIC Threads::Threads)
+{{if .GLSources}}
+option({{.Target}}_ENABLE_GL "Build the OpenGL target {{.Target}}_gl" OFF)
+if({{.Target}}_ENABLE_GL)
+  find_package(OpenGL REQUIRED)
+  find_package(glfw3 CONFIG QUIET)
+
+  add_library({{.Target}}_gl STATIC
+{{range .GLSources}}    ${CMAKE_CURRENT_SOURCE_DIR}/{{.}}
+{{end}}  )
+  set_target_properties({{.Target}}_gl PROPERTIES
+    CXX_STANDARD 14
+    CXX_STANDARD_REQUIRED ON)
+  target_link_libraries({{.Target}}_gl PUBLIC {{.Target}} OpenGL::GL)
+  if(glfw3_FOUND)
+    target_link_libraries({{.Target}}_gl PUBLIC glfw)
+  endif()
+endif()
+{{end}}`))
+
+var ninjaTmpl = template.Must(template.New("build.ninja").Parse(`# Code generated by go2cpp. DO NOT EDIT.
+
+cxx = c++
+cxxflags = -std=c++14 -pthread -O2
+includes ={{if .IncludeRoot}} -I{{.IncludeRoot}}{{end}}
+ar = ar
+objdir = obj
+
+rule cxx
+  command = $cxx $cxxflags $includes -MMD -MF $out.d -c $in -o $out
+  depfile = $out.d
+  deps = gcc
+  description = CXX $out
+
+rule ar
+  command = rm -f $out && $ar crs $out $in
+  description = AR $out
+
+{{range .Sources}}build $objdir/{{.}}.o: cxx {{.}}
+{{end}}
+build lib{{.Target}}.a: ar{{range .Sources}} $objdir/{{.}}.o{{end}}
+{{if .GLSources}}
+# The OpenGL target is not built by default. Run 'ninja lib{{.Target}}_gl.a' to build it.
+{{range .GLSources}}build $objdir/{{.}}.o: cxx {{.}}
+{{end}}
+build lib{{.Target}}_gl.a: ar{{range .GLSources}} $objdir/{{.}}.o{{end}}
+{{end}}
+default lib{{.Target}}.a
+`))
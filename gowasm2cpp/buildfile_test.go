@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncludeRoot(t *testing.T) {
+	cases := []struct {
+		In  string
+		Out string
+	}{
+		{In: "", Out: "."},
+		{In: "autogen/", Out: ".."},
+		{In: "./foo/autogen", Out: "../.."},
+		{In: "../autogen/", Out: ""},
+		{In: "/usr/include/autogen/", Out: ""},
+	}
+	for _, c := range cases {
+		if got, want := includeRoot(c.In), c.Out; got != want {
+			t.Errorf("includeRoot(%q): got: %q, want: %q", c.In, got, want)
+		}
+	}
+}
+
+// TestBuildFiles compares the generated build files with testdata/buildfile/<name>.golden.
+// Run `go test -update` to update the golden files.
+func TestBuildFiles(t *testing.T) {
+	manifest := &Manifest{
+		Sources:     []string{"game.cpp", "gl.cpp", "go.cpp", "inst.funcs0.cpp"},
+		Headers:     []string{"game.h", "gl.h", "go.h"},
+		Namespace:   "foo",
+		IncludePath: "autogen/",
+	}
+	out := MapOutput{}
+	o := newOutput(context.Background(), out, 0)
+	if err := writeCMake(o, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeNinja(o, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"CMakeLists.txt", "build.ninja"} {
+		got := out[name]
+		path := filepath.Join("testdata", "buildfile", name+".golden")
+		if *updateGolden {
+			if err := ioutil.WriteFile(path, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", name, got, want)
+		}
+	}
+}
+
+func TestBuildFilesWithoutGL(t *testing.T) {
+	manifest := &Manifest{
+		Sources: []string{"go.cpp"},
+	}
+	out := MapOutput{}
+	o := newOutput(context.Background(), out, 0)
+	if err := writeCMake(o, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeNinja(o, manifest); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"CMakeLists.txt", "build.ninja"} {
+		if got, want := string(out[name]), "go2cpp_gl"; strings.Contains(got, want) {
+			t.Errorf("%s must not have %q:\n%s", name, want, got)
+		}
+		if got, want := string(out[name]), "OpenGL"; strings.Contains(got, want) {
+			t.Errorf("%s must not have %q:\n%s", name, want, got)
+		}
+	}
+}
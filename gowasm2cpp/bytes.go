@@ -3,47 +3,29 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
 )
 
-func writeBytes(dir string, incpath string, namespace string) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "bytes.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := bytesHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_BYTES_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeBytes(out *output, incpath string, namespace string) error {
+	if err := out.writeFile("bytes.h", bytesHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+	}{
+		IncludeGuard: includeGuard(namespace) + "_BYTES_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "bytes.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := bytesCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("bytes.cpp", bytesCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// ConfigFileName is the default name of the configuration file.
+const ConfigFileName = "go2cpp.json"
+
+// Config represents a project configuration of gowasm2cpp.
+//
+// A configuration file is a JSON file like this:
+//
+//	{
+//	  "out": "autogen",
+//	  "include": "autogen",
+//	  "namespace": "go2cpp_autogen",
+//	  "wasm": "main.wasm",
+//	  "partition": "package",
+//	  "subsystems": [],
+//	  "max_memory": 1073741824,
+//	  "overrides": {
+//	    "runtime.wasmWrite": {
+//	      "signature": "(i32)",
+//	      "body": "  // Discard the output."
+//	    }
+//	  }
+//	}
+type Config struct {
+	// OutDir is the directory to write the generated files.
+	OutDir string `json:"out,omitempty"`
+
+	// Include is the include path of the generated files used in the generated #include directives.
+	Include string `json:"include,omitempty"`
+
+	// Wasm is the path to the Wasm file generated by Go.
+	Wasm string `json:"wasm,omitempty"`
+
+	// Namespace is the C++ namespace of the generated code.
+	Namespace string `json:"namespace,omitempty"`
+
+	// RuntimeNamespace is the C++ namespace of the runtime. If RuntimeNamespace is empty, Namespace is used.
+	// Programs generated with the same runtime namespace can share one runtime in one binary.
+	RuntimeNamespace string `json:"runtime_namespace,omitempty"`
+
+	// RuntimeInclude is the include path of the runtime files generated separately.
+	// RuntimeInclude is used only with ProgramOnly. If RuntimeInclude is empty, Include is used.
+	RuntimeInclude string `json:"runtime_include,omitempty"`
+
+	Options
+
+	// Module is the content of the Wasm module. If Module is not nil, Module is used instead of the file Wasm.
+	Module []byte `json:"-"`
+
+	// ModuleReader is a reader of the Wasm module. If ModuleReader is not nil, ModuleReader is used instead of the
+	// file Wasm.
+	ModuleReader io.Reader `json:"-"`
+
+	// Output is the destination of the generated files. If Output is nil, the files are written to the directory
+	// OutDir.
+	Output Output `json:"-"`
+}
+
+// readModule returns the content of the Wasm module.
+func (c *Config) readModule() ([]byte, error) {
+	if c.Module != nil {
+		return c.Module, nil
+	}
+	if c.ModuleReader != nil {
+		return ioutil.ReadAll(c.ModuleReader)
+	}
+	if c.Wasm == "" {
+		return nil, fmt.Errorf("gowasm2cpp: no Wasm module is specified")
+	}
+	return ioutil.ReadFile(c.Wasm)
+}
+
+// LoadConfig reads the configuration file at path.
+//
+// The relative paths of the output directory, the Wasm file and the snippets directory are resolved relative to the directory of the
+// configuration file. The include path is not changed as it is a path for the C++ compiler.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return nil, fmt.Errorf("gowasm2cpp: parsing %s failed: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	if c.OutDir != "" && !filepath.IsAbs(c.OutDir) {
+		c.OutDir = filepath.Join(dir, c.OutDir)
+	}
+	if c.Wasm != "" && !filepath.IsAbs(c.Wasm) {
+		c.Wasm = filepath.Join(dir, c.Wasm)
+	}
+	if c.SnippetsDir != "" && !filepath.IsAbs(c.SnippetsDir) {
+		c.SnippetsDir = filepath.Join(dir, c.SnippetsDir)
+	}
+	return &c, nil
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowasm2cpp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ConfigFileName)
+	if err := ioutil.WriteFile(path, []byte(`{
+  "out": "autogen",
+  "include": "autogen",
+  "wasm": "main.wasm",
+  "namespace": "foo",
+  "partition": "package",
+  "subsystems": [],
+  "max_memory": 1024,
+  "overrides": {
+    "runtime.wasmWrite": {
+      "signature": "(i32)",
+      "body": ""
+    }
+  }
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		OutDir:    filepath.Join(dir, "autogen"),
+		Include:   "autogen",
+		Wasm:      filepath.Join(dir, "main.wasm"),
+		Namespace: "foo",
+		Options: Options{
+			Partition:  PartitionPackage,
+			Subsystems: []Subsystem{},
+			MaxMemory:  1024,
+			Overrides: map[string]Override{
+				"runtime.wasmWrite": {
+					Signature: "(i32)",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadConfig(): got: %+v, want: %+v", got, want)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"unknown": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("LoadConfig() with an unknown field must return an error")
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FuncError represents an error to convert a Wasm function to C++.
+type FuncError struct {
+	// Name is the name of the Wasm function.
+	Name string
+
+	// Index is the index of the Wasm function.
+	Index int
+
+	Err error
+}
+
+func (e *FuncError) Error() string {
+	return fmt.Sprintf("%s (index: %d): %v", e.Name, e.Index, e.Err)
+}
+
+func (e *FuncError) Unwrap() error {
+	return e.Err
+}
+
+// FuncErrors is an aggregated error of the functions that cannot be converted in the keep-going mode.
+type FuncErrors []*FuncError
+
+func (e FuncErrors) Error() string {
+	lines := []string{fmt.Sprintf("gowasm2cpp: %d functions cannot be converted:", len(e))}
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// funcErrorCollector collects function errors in the keep-going mode.
+type funcErrorCollector struct {
+	errs FuncErrors
+	m    sync.Mutex
+}
+
+func (c *funcErrorCollector) add(err *FuncError) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+// err returns the collected errors sorted by the function indices, or nil if there are no errors.
+func (c *funcErrorCollector) err() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if len(c.errs) == 0 {
+		return nil
+	}
+	sort.Slice(c.errs, func(i, j int) bool {
+		return c.errs[i].Index < c.errs[j].Index
+	})
+	return c.errs
+}
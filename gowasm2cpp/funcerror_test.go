@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+func TestGenerateKeepGoing(t *testing.T) {
+	out := MapOutput{}
+	err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    inspectModule,
+		Output:    out,
+		Options: Options{
+			KeepGoing: true,
+		},
+	})
+	var errs FuncErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("GenerateWithConfig must return FuncErrors but got: %v", err)
+	}
+	if got, want := len(errs), 1; got != want {
+		t.Fatalf("len(errs): got: %d, want: %d", got, want)
+	}
+	if got, want := errs[0].Name, "main.bad"; got != want {
+		t.Errorf("errs[0].Name: got: %q, want: %q", got, want)
+	}
+	if got, want := errs[0].Index, 1; got != want {
+		t.Errorf("errs[0].Index: got: %d, want: %d", got, want)
+	}
+
+	// The function that cannot be converted becomes a stub that aborts, and the other function is converted.
+	var cpp []byte
+	for name, content := range out {
+		if strings.HasSuffix(name, ".cpp") {
+			cpp = append(cpp, content...)
+		}
+	}
+	stub := "void Inst::main_2ebad() {\n" +
+		`  std::cerr << "not supported: main.bad (index: 1): ` + errs[0].Err.Error() + `" << std::endl;` + "\n" +
+		"  std::abort();\n"
+	if !bytes.Contains(cpp, []byte(stub)) {
+		t.Errorf("the output doesn't have the stub of main.bad:\n%s", stub)
+	}
+	if !bytes.Contains(cpp, []byte("void Inst::main_2erun() {")) {
+		t.Errorf("the output doesn't have main.run")
+	}
+}
+
+func TestGenerateFuncError(t *testing.T) {
+	err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    inspectModule,
+		Output:    MapOutput{},
+	})
+	var ferr *FuncError
+	if !errors.As(err, &ferr) {
+		t.Fatalf("GenerateWithConfig must return a FuncError but got: %v", err)
+	}
+	if got, want := ferr.Name, "main.bad"; got != want {
+		t.Errorf("Name: got: %q, want: %q", got, want)
+	}
+}
+
+// bodyModule returns a Wasm module that has one function of () -> () exported as run, one page of memory, and
+// one i32 global. code is the instructions of the function without the last end.
+func bodyModule(code ...byte) []byte {
+	return Module(
+		Section(SectionType, Vec(FuncType(nil, nil))...),
+		Section(SectionFunction, Vec(U32(0))...),
+		Section(SectionMemory, Vec(Limits(1))...),
+		Section(SectionGlobal, Vec([]byte{I32, 0x01, 0x41, 0x00, 0x0b})...),
+		Section(SectionExport, Vec(Export("run", KindFunction, 0))...),
+		Section(SectionCode, Vec(Body(code...))...),
+	)
+}
+
+func TestGenerateMalformedBody(t *testing.T) {
+	cases := []struct {
+		Name string
+		Code []byte
+		Err  string
+	}{
+		{
+			Name: "stack underflow",
+			Code: []byte{0x1a},
+			Err:  "stack underflow",
+		},
+		{
+			Name: "function index",
+			Code: []byte{0x10, 0x05},
+			Err:  "invalid function index: 5",
+		},
+		{
+			Name: "type index",
+			Code: []byte{0x41, 0x00, 0x11, 0x09, 0x00},
+			Err:  "invalid type index: 9",
+		},
+		{
+			Name: "global index",
+			Code: []byte{0x23, 0x03, 0x1a},
+			Err:  "invalid global index: 3",
+		},
+		{
+			Name: "local index",
+			Code: []byte{0x20, 0x02, 0x1a},
+			Err:  "invalid local index: 2",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			err := GenerateWithConfig(context.Background(), Config{
+				Namespace: "foo",
+				Module:    bodyModule(c.Code...),
+				Output:    MapOutput{},
+			})
+			var ferr *FuncError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("GenerateWithConfig must return a FuncError but got: %v", err)
+			}
+			var berr *bodyError
+			if !errors.As(err, &berr) {
+				t.Errorf("the error must wrap a bodyError: %v", err)
+			}
+			if !strings.Contains(err.Error(), c.Err) {
+				t.Errorf("error: got: %v, want: containing %q", err, c.Err)
+			}
+		})
+	}
+}
@@ -3,47 +3,31 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
 )
 
-func writeGame(dir string, incpath string, namespace string) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "game.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := gameHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_GAME_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeGame(out *output, incpath string, namespace string, runtime runtimeRef) error {
+	if err := out.writeFile("game.h", gameHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+	}{
+		IncludeGuard: includeGuard(namespace) + "_GAME_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "game.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := gameCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("game.cpp", gameCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+		Runtime     runtimeRef
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+		Runtime:     runtime,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
@@ -93,7 +77,7 @@ var gameCppTmpl = template.Must(template.New("game.cpp").Parse(`// Code generate
 
 #include "{{.IncludePath}}game.h"
 
-#include "{{.IncludePath}}gl.h"
+#include "{{.Runtime.IncludePath}}gl.h"
 
 namespace {{.Namespace}} {
 
@@ -4,14 +4,17 @@ package gowasm2cpp
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 
-	"github.com/go-interpreter/wagon/wasm"
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -46,7 +49,6 @@ func includeGuard(str string) string {
 }
 
 type wasmFunc struct {
-	Mod     *wasm.Module
 	Funcs   []*wasmFunc
 	Types   []*wasmType
 	Globals []*wasmGlobal
@@ -55,6 +57,9 @@ type wasmFunc struct {
 	Index   int
 	Import  bool
 	BodyStr string
+
+	// errs collects the errors instead of failing in the keep-going mode. errs is nil in the normal mode.
+	errs *funcErrorCollector
 }
 
 func (f *wasmFunc) Identifier() string {
@@ -89,15 +94,7 @@ func wasmTypeToReturnType(v wasm.ValueType) returnType {
 }
 
 func (f *wasmFunc) CppDecl(indent string, abstract bool, override bool) (string, error) {
-	var retType returnType
-	switch ts := f.Wasm.Sig.ReturnTypes; len(ts) {
-	case 0:
-		retType = returnTypeVoid
-	case 1:
-		retType = wasmTypeToReturnType(ts[0])
-	default:
-		return "", fmt.Errorf("the number of return values must be 0 or 1 but %d", len(ts))
-	}
+	retType := resultsCpp(f.Wasm.Sig.ReturnTypes)
 
 	var args []string
 	for i, t := range f.Wasm.Sig.ParamTypes {
@@ -117,7 +114,7 @@ func (f *wasmFunc) CppDecl(indent string, abstract bool, override bool) (string,
 		OriginalName: f.Wasm.Name,
 		Name:         identifierFromString(f.Wasm.Name),
 		Index:        f.Index,
-		ReturnType:   retType.Cpp(),
+		ReturnType:   retType,
 		Args:         strings.Join(args, ", "),
 		Abstract:     abstract,
 		Override:     override,
@@ -134,15 +131,7 @@ func (f *wasmFunc) CppDecl(indent string, abstract bool, override bool) (string,
 }
 
 func (f *wasmFunc) CppImpl(className string, indent string) (string, error) {
-	var retType returnType
-	switch ts := f.Wasm.Sig.ReturnTypes; len(ts) {
-	case 0:
-		retType = returnTypeVoid
-	case 1:
-		retType = wasmTypeToReturnType(ts[0])
-	default:
-		return "", fmt.Errorf("the number of return values must be 0 or 1 but %d", len(ts))
-	}
+	retType := resultsCpp(f.Wasm.Sig.ReturnTypes)
 
 	var args []string
 	for i, t := range f.Wasm.Sig.ParamTypes {
@@ -164,9 +153,23 @@ func (f *wasmFunc) CppImpl(className string, indent string) (string, error) {
 		var err error
 		body, err = f.bodyToCpp()
 		if err != nil {
-			return "", err
+			ferr := &FuncError{
+				Name:  f.Wasm.Name,
+				Index: f.Index,
+				Err:   err,
+			}
+			if f.errs == nil {
+				return "", ferr
+			}
+			f.errs.add(ferr)
+			locals = nil
+			body = []string{
+				fmt.Sprintf(`  std::cerr << %s << std::endl;`, strconv.Quote("not supported: "+ferr.Error())),
+				"  std::abort();",
+			}
+		} else {
+			locals = removeUnusedLocalVariables(locals, body)
 		}
-		locals = removeUnusedLocalVariables(locals, body)
 	} else {
 		// TODO: Use error function.
 		ident := identifierFromString(f.Wasm.Name)
@@ -190,7 +193,7 @@ func (f *wasmFunc) CppImpl(className string, indent string) (string, error) {
 		Name:         identifierFromString(f.Wasm.Name),
 		Class:        className,
 		Index:        f.Index,
-		ReturnType:   retType.Cpp(),
+		ReturnType:   retType,
 		Args:         strings.Join(args, ", "),
 		Locals:       locals,
 		Body:         body,
@@ -250,22 +253,14 @@ type wasmExport struct {
 func (e *wasmExport) CppDecl(indent string) (string, error) {
 	f := e.Funcs[e.Index]
 
-	var retType returnType
-	switch ts := f.Wasm.Sig.ReturnTypes; len(ts) {
-	case 0:
-		retType = returnTypeVoid
-	case 1:
-		retType = wasmTypeToReturnType(ts[0])
-	default:
-		return "", fmt.Errorf("the number of return values must be 0 or 1 but %d", len(ts))
-	}
+	retType := resultsCpp(f.Wasm.Sig.ReturnTypes)
 
 	var args []string
 	for i, t := range f.Wasm.Sig.ParamTypes {
 		args = append(args, fmt.Sprintf("%s arg%d", wasmTypeToReturnType(t).Cpp(), i))
 	}
 
-	str := fmt.Sprintf(`%s %s(%s);`, retType.Cpp(), e.Name, strings.Join(args, ", "))
+	str := fmt.Sprintf(`%s %s(%s);`, retType, e.Name, strings.Join(args, ", "))
 
 	lines := strings.Split(str, "\n")
 	for i := range lines {
@@ -278,16 +273,10 @@ func (e *wasmExport) CppImpl(indent string) (string, error) {
 	f := e.Funcs[e.Index]
 
 	var ret string
-	var retType returnType
-	switch ts := f.Wasm.Sig.ReturnTypes; len(ts) {
-	case 0:
-		retType = returnTypeVoid
-	case 1:
+	if len(f.Wasm.Sig.ReturnTypes) > 0 {
 		ret = "return "
-		retType = wasmTypeToReturnType(ts[0])
-	default:
-		return "", fmt.Errorf("the number of return values must be 0 or 1 but %d", len(ts))
 	}
+	retType := resultsCpp(f.Wasm.Sig.ReturnTypes)
 
 	var args []string
 	var argsToPass []string
@@ -299,7 +288,7 @@ func (e *wasmExport) CppImpl(indent string) (string, error) {
 	str := fmt.Sprintf(`%s Inst::%s(%s) {
   %s%s(%s);
 }
-`, retType.Cpp(), e.Name, strings.Join(args, ", "), ret, identifierFromString(f.Wasm.Name), strings.Join(argsToPass, ", "))
+`, retType, e.Name, strings.Join(args, ", "), ret, identifierFromString(f.Wasm.Name), strings.Join(argsToPass, ", "))
 
 	lines := strings.Split(str, "\n")
 	for i := range lines {
@@ -309,13 +298,39 @@ func (e *wasmExport) CppImpl(indent string) (string, error) {
 }
 
 type wasmGlobal struct {
-	Type  wasm.ValueType
-	Index int
-	Init  int
+	Type    wasm.ValueType
+	Index   int
+	Mutable bool
+
+	// Init is the bits of the initial value.
+	Init uint64
 }
 
+// Cpp returns the declaration of the global as a member of Inst.
+// An immutable global is a static constexpr member if possible.
 func (g *wasmGlobal) Cpp() string {
-	return fmt.Sprintf("%s global%d_ = %d;", wasmTypeToReturnType(g.Type).Cpp(), g.Index, g.Init)
+	t := wasmTypeToReturnType(g.Type).Cpp()
+	v, constexpr := cppConstant(g.Type, g.Init)
+	switch {
+	case g.Mutable:
+		return fmt.Sprintf("%s global%d_ = %s;", t, g.Index, v)
+	case constexpr:
+		return fmt.Sprintf("static constexpr %s global%d_ = %s;", t, g.Index, v)
+	default:
+		return fmt.Sprintf("const %s global%d_ = %s;", t, g.Index, v)
+	}
+}
+
+// CppDefinition returns the definition of the global out of the class Inst if needed.
+// A static constexpr member needs a definition when it is odr-used before C++17.
+func (g *wasmGlobal) CppDefinition() string {
+	if g.Mutable {
+		return ""
+	}
+	if _, constexpr := cppConstant(g.Type, g.Init); !constexpr {
+		return ""
+	}
+	return fmt.Sprintf("constexpr %s Inst::global%d_;\n", wasmTypeToReturnType(g.Type).Cpp(), g.Index)
 }
 
 type wasmType struct {
@@ -324,248 +339,442 @@ type wasmType struct {
 }
 
 func (t *wasmType) Cpp() (string, error) {
-	var retType returnType
-	switch ts := t.Sig.ReturnTypes; len(ts) {
-	case 0:
-		retType = returnTypeVoid
-	case 1:
-		retType = wasmTypeToReturnType(ts[0])
-	default:
-		return "", fmt.Errorf("the number of return values must be 0 or 1 but %d", len(ts))
-	}
+	retType := resultsCpp(t.Sig.ReturnTypes)
 	var args []string
 	for i, t := range t.Sig.ParamTypes {
 		args = append(args, fmt.Sprintf("%s arg%d", wasmTypeToReturnType(t).Cpp(), i))
 	}
 
-	return fmt.Sprintf("%s (Inst::*)(%s)", retType.Cpp(), strings.Join(args, ", ")), nil
+	return fmt.Sprintf("%s (Inst::*)(%s)", retType, strings.Join(args, ", ")), nil
 }
 
-func Generate(outDir string, include string, wasmFile string, namespace string) error {
-	f, err := os.Open(wasmFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+// Subsystem is an optional part of the C++ runtime.
+type Subsystem string
 
-	mod, err := wasm.DecodeModule(f)
-	if err != nil {
-		return err
-	}
+const (
+	// SubsystemGL is the OpenGL bindings and the game loop (gl.cpp and game.cpp).
+	SubsystemGL Subsystem = "gl"
+)
 
-	var types []*wasmType
-	for i, e := range mod.Types.Entries {
-		e := e
-		types = append(types, &wasmType{
-			Sig:   &e,
-			Index: i,
-		})
-	}
+var allSubsystems = []Subsystem{
+	SubsystemGL,
+}
 
-	var globals []*wasmGlobal
-	for i, e := range mod.Global.Globals {
-		// TODO: Consider mutability.
-		// TODO: Use e.Type.Init.
-		globals = append(globals, &wasmGlobal{
-			Type:  e.Type.Type,
-			Index: i,
-			Init:  0,
-		})
-	}
+// Options represents options for Generate.
+type Options struct {
+	// Partition is the strategy to split functions into C++ files.
+	// If Partition is empty, PartitionIndex is used.
+	Partition Partition `json:"partition,omitempty"`
+
+	// GroupSize is the number of functions in one C++ file.
+	// For PartitionPackage, a package of more than GroupSize functions is split into the buckets of GroupSize
+	// functions on average.
+	// If GroupSize is 0, 64 is used.
+	GroupSize int `json:"group_size,omitempty"`
+
+	// EmitCMake indicates whether to generate CMakeLists.txt to build the C++ files as a static library.
+	EmitCMake bool `json:"emit_cmake,omitempty"`
+
+	// EmitNinja indicates whether to generate build.ninja to build the C++ files as a static library.
+	EmitNinja bool `json:"emit_ninja,omitempty"`
+
+	// Unity is the number of amalgamated C++ files for unity builds.
+	// If Unity is 1, all the C++ files except for OpenGL ones are merged into go2cpp_all.cpp.
+	// If Unity is more than 1, the C++ files are merged into go2cpp_all0.cpp, go2cpp_all1.cpp, and so on.
+	// If Unity is 0, the C++ files are not merged.
+	Unity int `json:"unity,omitempty"`
+
+	// Overrides maps Wasm function names to C++ function bodies that replace the original implementations.
+	// Both imported and defined functions can be overridden. The arguments are named local0_, local1_, and so on.
+	// Overrides take precedence over Registry and SnippetsDir.
+	Overrides map[string]Override `json:"overrides,omitempty"`
+
+	// Subsystems is the optional parts of the C++ runtime to generate.
+	// If Subsystems is nil, all the subsystems are generated.
+	Subsystems []Subsystem `json:"subsystems,omitempty"`
+
+	// ReservedMemory is the number of bytes reserved for the linear memory at start.
+	// Reserving enough memory avoids reallocation when the memory grows.
+	// If ReservedMemory is 0, 1GiB is reserved.
+	ReservedMemory int64 `json:"reserved_memory,omitempty"`
+
+	// MaxMemory is the maximum number of bytes of the linear memory. Growing the memory beyond this fails.
+	// If MaxMemory is 0, the limit is 4GiB, which is the limit of Wasm.
+	MaxMemory int64 `json:"max_memory,omitempty"`
+
+	// KeepGoing indicates whether to continue the generation when functions cannot be converted.
+	// Such functions are replaced with stubs that abort the program, and then GenerateWithOptions returns FuncErrors
+	// after generating all the files.
+	KeepGoing bool `json:"keep_going,omitempty"`
+
+	// Registry is the C++ function bodies for imported functions and overridden functions.
+	// If Registry is nil, DefaultRegistry() is used.
+	Registry *Registry `json:"-"`
+
+	// SnippetsDir is a directory of C++ snippets of function bodies. The snippets are added to the registry.
+	// See Registry.LoadDir for the format.
+	SnippetsDir string `json:"snippets_dir,omitempty"`
+
+	// KeepUnreachable indicates whether to keep the functions unreachable from the exports and the tables.
+	// By default, such functions are not generated.
+	KeepUnreachable bool `json:"keep_unreachable,omitempty"`
+
+	// RuntimeOnly indicates whether to generate only the runtime files (bits, bytes, gl, js and taskqueue).
+	// The runtime doesn't depend on the Wasm module and can be shared by multiple programs in one binary.
+	RuntimeOnly bool `json:"runtime_only,omitempty"`
+
+	// ProgramOnly indicates whether to generate only the program files (go, game, host, inst, mem and wasi).
+	// The program files refer to the runtime generated with RuntimeOnly.
+	//
+	// The runtime and the program must be generated to different directories.
+	ProgramOnly bool `json:"program_only,omitempty"`
+}
 
-	var ifs []*wasmFunc
-	for i, e := range mod.Import.Entries {
-		name := e.FieldName
-		ifs = append(ifs, &wasmFunc{
-			Type: types[e.Type.(wasm.FuncImport).Type],
-			Wasm: wasm.Function{
-				Sig:  types[e.Type.(wasm.FuncImport).Type].Sig,
-				Name: name,
-			},
-			Globals: globals,
-			Index:   i,
-			Import:  true,
-			BodyStr: importFuncBodies[name],
-		})
+// mode returns the mode recorded in Manifest.Mode.
+func (o *Options) mode() string {
+	switch {
+	case o.RuntimeOnly:
+		return "runtime"
+	case o.ProgramOnly:
+		return "program"
 	}
+	return ""
+}
 
-	// There is a bug that signature and body are shifted (go-interpreter/wagon#190).
-	var names wasm.NameMap
-	if c := mod.Custom(wasm.CustomSectionName); c != nil {
-		var nsec wasm.NameSection
-		if err := nsec.UnmarshalWASM(bytes.NewReader(c.Data)); err != nil {
-			return err
+// Override is a C++ function body that replaces the original implementation of a Wasm function.
+type Override struct {
+	// Signature is the Wasm signature of the function like "(i32, i32) -> i64" or "(i32)".
+	// Generating C++ files fails when the signature doesn't match with the Wasm function type.
+	Signature string `json:"signature"`
+
+	// Body is the C++ function body.
+	Body string `json:"body"`
+}
+
+// registry returns the registry with the snippets and the overrides.
+func (o *Options) registry() (*Registry, error) {
+	r := o.Registry
+	if r == nil {
+		r = DefaultRegistry()
+	}
+	if o.SnippetsDir == "" && len(o.Overrides) == 0 {
+		return r, nil
+	}
+	r = r.clone()
+	if o.SnippetsDir != "" {
+		if err := r.LoadDir(o.SnippetsDir); err != nil {
+			return nil, err
 		}
-		if len(nsec.Types[wasm.NameFunction]) > 0 {
-			sub, err := nsec.Decode(wasm.NameFunction)
-			if err != nil {
-				return err
-			}
-			names = sub.(*wasm.FunctionNames).Names
+	}
+	for name, ov := range o.Overrides {
+		if err := r.Register(name, ov.Signature, ov.Body); err != nil {
+			return nil, fmt.Errorf("gowasm2cpp: override for %q: %v", name, err)
 		}
 	}
-	var fs []*wasmFunc
-	for i, t := range mod.Function.Types {
-		name := names[uint32(i+len(mod.Import.Entries))]
-		bodyStr, ok := specialFunctionBodies[name]
-		var body *wasm.FunctionBody
-		if !ok {
-			body = &mod.Code.Bodies[i]
+	return r, nil
+}
+
+func (o *Options) subsystemEnabled(s Subsystem) bool {
+	if o.Subsystems == nil {
+		return true
+	}
+	for _, s2 := range o.Subsystems {
+		if s2 == s {
+			return true
 		}
-		fs = append(fs, &wasmFunc{
-			Type: types[t],
-			Wasm: wasm.Function{
-				Sig:  types[t].Sig,
-				Body: body,
-				Name: name,
-			},
-			Globals: globals,
-			Index:   i + len(mod.Import.Entries),
-			BodyStr: bodyStr,
-		})
 	}
+	return false
+}
 
-	var exports []*wasmExport
-	for _, e := range mod.Export.Entries {
-		switch e.Kind {
-		case wasm.ExternalFunction:
-			exports = append(exports, &wasmExport{
-				Index: int(e.Index),
-				Name:  e.FieldStr,
-			})
-		case wasm.ExternalMemory:
-			// Ignore
-		default:
-			return fmt.Errorf("export type %d is not implemented", e.Kind)
+func (o *Options) validate() error {
+	if o.RuntimeOnly && o.ProgramOnly {
+		return fmt.Errorf("gowasm2cpp: runtime-only and program-only cannot be specified at the same time")
+	}
+	if o.Unity < 0 {
+		return fmt.Errorf("gowasm2cpp: invalid unity file number: %d", o.Unity)
+	}
+	if o.ReservedMemory < 0 {
+		return fmt.Errorf("gowasm2cpp: invalid reserved memory size: %d", o.ReservedMemory)
+	}
+	if o.MaxMemory < 0 || o.MaxMemory > maxMemory {
+		return fmt.Errorf("gowasm2cpp: invalid max memory size: %d", o.MaxMemory)
+	}
+loop:
+	for _, s := range o.Subsystems {
+		for _, s2 := range allSubsystems {
+			if s == s2 {
+				continue loop
+			}
 		}
+		return fmt.Errorf("gowasm2cpp: unknown subsystem: %q", s)
+	}
+	return nil
+}
+
+// validateMemory returns an error if the options don't fit the linear memory of the module with the limits.
+func (o *Options) validateMemory(limits wasm.Limits) error {
+	if init := int64(limits.Initial) * pageSize; o.MaxMemory != 0 && o.MaxMemory < init {
+		return fmt.Errorf("gowasm2cpp: max memory size %d is less than the initial memory size of the module %d", o.MaxMemory, init)
+	}
+	return nil
+}
+
+// Generate generates C++ files from the Wasm file.
+func Generate(outDir string, include string, wasmFile string, namespace string) error {
+	return GenerateWithOptions(outDir, include, wasmFile, namespace, nil)
+}
+
+// GenerateWithOptions generates C++ files from the Wasm file with the given options.
+// If options is nil, the default options are used.
+func GenerateWithOptions(outDir string, include string, wasmFile string, namespace string, options *Options) error {
+	config := Config{
+		OutDir:    outDir,
+		Include:   include,
+		Wasm:      wasmFile,
+		Namespace: namespace,
+	}
+	if options != nil {
+		config.Options = *options
+	}
+	return GenerateWithConfig(context.Background(), config)
+}
+
+// GenerateWithConfig generates C++ files from the Wasm module with the given configuration.
+//
+// The Wasm module is read from config.Module, config.ModuleReader or the file config.Wasm in this order.
+// The generated files are written to config.Output, or the directory config.OutDir if config.Output is nil.
+//
+// With Options.RuntimeOnly, only the runtime files are generated and no Wasm module is required. With
+// Options.ProgramOnly, only the program files are generated and they refer to the runtime generated separately.
+func GenerateWithConfig(ctx context.Context, config Config) error {
+	options := &config.Options
+	if err := options.validate(); err != nil {
+		return err
 	}
 
-	allfs := append(ifs, fs...)
-	for _, e := range exports {
-		e.Funcs = allfs
+	namespace := config.Namespace
+	incpath := includePath(config.Include)
+	runtime := runtimeRef{
+		IncludePath: incpath,
 	}
-	for _, f := range ifs {
-		f.Mod = mod
-		f.Funcs = allfs
-		f.Types = types
+	runtimeNamespace := namespace
+	if config.RuntimeNamespace != "" && config.RuntimeNamespace != namespace {
+		runtimeNamespace = config.RuntimeNamespace
+		runtime.Namespace = runtimeNamespace
 	}
-	for _, f := range fs {
-		f.Mod = mod
-		f.Funcs = allfs
-		f.Types = types
+	if options.ProgramOnly && config.RuntimeInclude != "" {
+		runtime.IncludePath = includePath(config.RuntimeInclude)
 	}
 
-	if mod.Start != nil {
-		return fmt.Errorf("start section must be nil but not")
+	sink := config.Output
+	if sink == nil {
+		sink = DirOutput(config.OutDir)
+	}
+	out := newOutput(ctx, sink, options.Unity)
+	if err := out.checkMode(options.mode()); err != nil {
+		return err
 	}
 
-	tables := make([][]uint32, len(mod.Table.Entries))
-	for _, e := range mod.Elements.Entries {
-		v, err := mod.ExecInitExpr(e.Offset)
+	manifest := &Manifest{
+		Namespace:   namespace,
+		IncludePath: incpath,
+		Imports:     []ManifestImport{},
+		Exports:     []string{},
+		Mode:        options.mode(),
+	}
+	if runtime.Namespace != "" {
+		manifest.RuntimeNamespace = runtime.Namespace
+	}
+
+	var g errgroup.Group
+	var errs *funcErrorCollector
+	// wasi indicates whether the program is a WASI module, which doesn't need the JavaScript runtime.
+	var wasi bool
+	if !options.RuntimeOnly {
+		bin, err := config.readModule()
 		if err != nil {
 			return err
 		}
-		offset := v.(int32)
-		if diff := int(offset) + int(len(e.Elems)) - int(len(tables[e.Index])); diff > 0 {
-			tables[e.Index] = append(tables[e.Index], make([]uint32, diff)...)
+		registry, err := options.registry()
+		if err != nil {
+			return err
 		}
-		copy(tables[e.Index][offset:], e.Elems)
-	}
-
-	var data []wasmData
-	for _, e := range mod.Data.Entries {
-		offset, err := mod.ExecInitExpr(e.Offset)
+		m, err := newModule(bin, registry, options.Overrides)
 		if err != nil {
 			return err
 		}
-		data = append(data, wasmData{
-			Offset: int(offset.(int32)),
-			Data:   e.Data,
-		})
+		wasi = m.wasi
+		if err := options.validateMemory(m.mod.Memories[0].Limits); err != nil {
+			return err
+		}
+		if !options.KeepUnreachable {
+			manifest.RemovedFuncs, manifest.RemovedCodeSize = m.removeUnreachableFuncs()
+		}
+		if options.KeepGoing {
+			errs = &funcErrorCollector{}
+			for _, f := range m.funcs {
+				f.errs = errs
+			}
+		}
+		groups, err := partitionFuncs(m.funcs, options.Partition, options.GroupSize)
+		if err != nil {
+			return err
+		}
+		writeProgram(&g, out, incpath, namespace, runtime, options, m, groups)
+
+		hash := sha256.Sum256(bin)
+		manifest.WasmSHA256 = hex.EncodeToString(hash[:])
+		manifest.GoVersion = goVersion(m.mod)
+		for _, e := range m.mod.Imports {
+			manifest.Imports = append(manifest.Imports, ManifestImport{
+				Module: e.ModuleName,
+				Name:   e.FieldName,
+			})
+		}
+		for _, e := range m.exports {
+			manifest.Exports = append(manifest.Exports, e.Name)
+		}
+	}
+	if !options.ProgramOnly {
+		writeRuntime(&g, out, incpath, runtimeNamespace, options, wasi)
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
 	}
 
-	var incpath string
-	if include != "" {
-		include = filepath.ToSlash(include)
-		if include[len(include)-1] != '/' {
-			include += "/"
+	if err := out.flushUnity(); err != nil {
+		return err
+	}
+	// The set of the C++ files changes with the number of functions, the unity mode or the subsystems.
+	for _, pattern := range []string{"*.cpp", "*.h"} {
+		if err := out.removeStaleFiles(pattern); err != nil {
+			return err
 		}
 	}
 
-	var g errgroup.Group
-	g.Go(func() error {
-		{
-			out, err := os.Create(filepath.Join(outDir, "go.h"))
-			if err != nil {
-				return err
-			}
-			defer out.Close()
-
-			if err := goHTmpl.Execute(out, struct {
-				IncludeGuard string
-				IncludePath  string
-				Namespace    string
-				ImportFuncs  []*wasmFunc
-			}{
-				IncludeGuard: includeGuard(namespace) + "_GO_H",
-				IncludePath:  incpath,
-				Namespace:    namespace,
-				ImportFuncs:  ifs,
-			}); err != nil {
-				return err
-			}
+	manifest.addFiles(out.files())
+
+	if options.EmitCMake {
+		if err := writeCMake(out, manifest); err != nil {
+			return err
 		}
-		{
-			out, err := os.Create(filepath.Join(outDir, "go.cpp"))
-			if err != nil {
-				return err
-			}
-			defer out.Close()
-
-			if err := goCppTmpl.Execute(out, struct {
-				IncludePath string
-				Namespace   string
-				ImportFuncs []*wasmFunc
-			}{
-				IncludePath: incpath,
-				Namespace:   namespace,
-				ImportFuncs: ifs,
-			}); err != nil {
-				return err
-			}
+	}
+	if options.EmitNinja {
+		if err := writeNinja(out, manifest); err != nil {
+			return err
 		}
-		return nil
-	})
-	g.Go(func() error {
-		return writeBits(outDir, incpath, namespace)
-	})
-	g.Go(func() error {
-		return writeGame(outDir, incpath, namespace)
-	})
+	}
+	if err := writeManifest(out, manifest); err != nil {
+		return err
+	}
+
+	if errs != nil {
+		return errs.err()
+	}
+	return nil
+}
+
+// includePath returns the prefix of the paths in #include directives for the include directory.
+func includePath(include string) string {
+	if include == "" {
+		return ""
+	}
+	incpath := filepath.ToSlash(include)
+	if incpath[len(incpath)-1] != '/' {
+		incpath += "/"
+	}
+	return incpath
+}
+
+// runtimeRef is a reference to the runtime files from the program files.
+type runtimeRef struct {
+	// IncludePath is the prefix of the paths to include the runtime headers.
+	IncludePath string
+
+	// Namespace is the namespace of the runtime. Namespace is empty when the runtime is in the program's namespace.
+	Namespace string
+}
+
+// writeRuntime writes the runtime files, which don't depend on the Wasm module.
+//
+// If wasi is true, the files for JavaScript and OpenGL are not written as a WASI module never uses them.
+func writeRuntime(g *errgroup.Group, out *output, incpath string, namespace string, options *Options, wasi bool) {
 	g.Go(func() error {
-		return writeGL(outDir, incpath, namespace)
+		return writeBits(out, incpath, namespace)
 	})
+	if !wasi && options.subsystemEnabled(SubsystemGL) {
+		g.Go(func() error {
+			return writeGL(out, incpath, namespace)
+		})
+	}
+	if !wasi {
+		g.Go(func() error {
+			return writeJS(out, incpath, namespace)
+		})
+		g.Go(func() error {
+			return writeTaskQueue(out, incpath, namespace)
+		})
+	}
 	g.Go(func() error {
-		return writeJS(outDir, incpath, namespace)
+		return writeBytes(out, incpath, namespace)
 	})
+}
+
+// writeProgram writes the program files for the Wasm module.
+func writeProgram(g *errgroup.Group, out *output, incpath string, namespace string, runtime runtimeRef, options *Options, m *module, groups []funcGroup) {
 	g.Go(func() error {
-		return writeTaskQueue(outDir, incpath, namespace)
+		if err := out.writeFile("go.h", goHTmpl, struct {
+			IncludeGuard string
+			IncludePath  string
+			Namespace    string
+			Runtime      runtimeRef
+			ImportFuncs  []*wasmFunc
+			WASI         bool
+		}{
+			IncludeGuard: includeGuard(namespace) + "_GO_H",
+			IncludePath:  incpath,
+			Namespace:    namespace,
+			Runtime:      runtime,
+			ImportFuncs:  uniqueImportFuncs(m.importFuncs),
+			WASI:         m.wasi,
+		}); err != nil {
+			return err
+		}
+		if err := out.writeFile("go.cpp", goCppTmpl, struct {
+			IncludePath string
+			Namespace   string
+			ImportFuncs []*wasmFunc
+			WASI        bool
+		}{
+			IncludePath: incpath,
+			Namespace:   namespace,
+			ImportFuncs: uniqueImportFuncs(m.importFuncs),
+			WASI:        m.wasi,
+		}); err != nil {
+			return err
+		}
+		return nil
 	})
+	if !m.wasi && options.subsystemEnabled(SubsystemGL) {
+		g.Go(func() error {
+			return writeGame(out, incpath, namespace, runtime)
+		})
+	}
 	g.Go(func() error {
-		return writeBytes(outDir, incpath, namespace)
+		return writeHost(out, incpath, namespace, m.hostFuncs)
 	})
 	g.Go(func() error {
-		return writeInst(outDir, incpath, namespace, ifs, fs, exports, globals, types, tables)
+		return writeInst(out, incpath, namespace, runtime, m.numFuncs(), m.importFuncs, m.funcs, groups, m.exports, m.globals, m.types, m.tables)
 	})
 	g.Go(func() error {
-		return writeMem(outDir, incpath, namespace, int(mod.Memory.Entries[0].Limits.Initial), data)
+		return writeMem(out, incpath, namespace, runtime, m.mod.Memories[0].Limits, options.ReservedMemory, options.MaxMemory, m.data)
 	})
-
-	if err := g.Wait(); err != nil {
-		return err
+	if m.wasi {
+		g.Go(func() error {
+			return writeWASI(out, incpath, namespace, runtime)
+		})
 	}
-
-	return nil
 }
 
 var goHTmpl = template.Must(template.New("go.h").Parse(`// Code generated by go2cpp. DO NOT EDIT.
@@ -583,24 +792,47 @@ var goHTmpl = template.Must(template.New("go.h").Parse(`// Code generated by go2
 #include <string>
 #include <unordered_map>
 #include <vector>
-#include "{{.IncludePath}}bytes.h"
-#include "{{.IncludePath}}js.h"
+#include "{{.Runtime.IncludePath}}bytes.h"
+#include "{{.IncludePath}}host.h"
+{{- if not .WASI}}
+#include "{{.Runtime.IncludePath}}js.h"
+{{- end}}
 #include "{{.IncludePath}}inst.h"
 #include "{{.IncludePath}}mem.h"
-#include "{{.IncludePath}}taskqueue.h"
+{{- if .WASI}}
+#include "{{.IncludePath}}wasi.h"
+{{- else}}
+#include "{{.Runtime.IncludePath}}taskqueue.h"
+{{- end}}
 
 namespace {{.Namespace}} {
-
+{{if .Runtime.Namespace}}
+using namespace {{.Runtime.Namespace}};
+{{end}}
 class Mem;
 
 class Go {
 public:
   Go();
+
+  // host implements the functions imported from modules other than the Go modules. host can be nil if the Wasm
+  // module doesn't have such imports. host must outlive the Go object.
+  explicit Go(Host* host);
+
   int Run();
   int Run(int argc, char** argv);
   int Run(const std::vector<std::string>& args);
+{{- if not .WASI}}
   void EnqueueTask(std::function<void()> task);
-
+{{- end}}
+{{if .WASI}}
+  // SetEnv sets the environment variables like "KEY=VALUE" for the WASI program.
+  void SetEnv(std::vector<std::string> env);
+
+  // PreopenDir makes the host directory host_path accessible as guest_path, e.g., "/", from the WASI program.
+  // Call PreopenDir before Run.
+  void PreopenDir(std::string guest_path, std::string host_path);
+{{end}}
 private:
   class Import : public IImport {
   public:
@@ -612,7 +844,15 @@ private:
   private:
     Go* go_;
   };
-
+{{if .WASI}}
+  Import import_;
+  Host* host_ = nullptr;
+  std::unique_ptr<Inst> inst_;
+  std::unique_ptr<Mem> mem_;
+  bool exited_ = false;
+  int32_t exit_code_ = 0;
+  Wasi wasi_;
+{{- else}}
   class GoObject : public Object {
   public:
     explicit GoObject(Go* go);
@@ -644,6 +884,7 @@ private:
   TaskQueue task_queue_;
 
   Value pending_event_;
+  Host* host_ = nullptr;
   std::unordered_map<int32_t, Value> cached_events_;
   std::unordered_map<int32_t, std::unique_ptr<Timer>> scheduled_timeouts_;
   int32_t next_callback_timeout_id_ = 1;
@@ -658,6 +899,7 @@ private:
   int32_t exit_code_ = 0;
 
   std::chrono::high_resolution_clock::time_point start_time_point_ = std::chrono::high_resolution_clock::now();
+{{- end}}
 };
 
 }
@@ -677,7 +919,7 @@ var goCppTmpl = template.Must(template.New("go.cpp").Parse(`// Code generated by
 #include <random>
 
 namespace {{.Namespace}} {
-
+{{if not .WASI}}
 namespace {
 
 void error(const std::string& msg) {
@@ -687,11 +929,18 @@ void error(const std::string& msg) {
 }
 
 }
-
+{{end}}
 Go::Go()
+    : Go{nullptr} {
+}
+
+Go::Go(Host* host)
     : import_{this},
+{{- if not .WASI}}
       debug_writer_{std::cerr},
-      pending_event_{Value::Null()} {
+      pending_event_{Value::Null()},
+{{- end}}
+      host_{host} {
 }
 
 int Go::Run() {
@@ -706,7 +955,23 @@ int Go::Run(int argc, char** argv) {
 int Go::Run(const std::vector<std::string>& args) {
   mem_ = std::make_unique<Mem>();
   inst_ = std::make_unique<Inst>(mem_.get(), &import_);
+  if (host_) {
+    host_->mem_ = mem_.get();
+  }
+{{if .WASI}}
+  exited_ = false;
+  exit_code_ = 0;
+  wasi_.Start(mem_.get(), args);
+  try {
+    inst_->_start();
+  } catch (const Wasi::Exit& e) {
+    exit_code_ = e.code;
+  }
+  wasi_.Finish();
+  exited_ = true;
 
+  return static_cast<int>(exit_code_);
+{{- else}}
   values_ = {
     Value{std::nan("")},
     Value{0.0},
@@ -777,14 +1042,24 @@ int Go::Run(const std::vector<std::string>& args) {
   }
 
   return static_cast<int>(exit_code_);
+{{- end}}
+}
+{{if .WASI}}
+void Go::SetEnv(std::vector<std::string> env) {
+  wasi_.SetEnv(std::move(env));
 }
 
+void Go::PreopenDir(std::string guest_path, std::string host_path) {
+  wasi_.PreopenDir(std::move(guest_path), std::move(host_path));
+}
+{{end}}
 Go::Import::Import(Go* go)
     : go_{go} {
 }
 
 {{range $value := .ImportFuncs}}{{$value.CppImpl "Go::Import" ""}}
 {{end}}
+{{- if not .WASI}}
 
 Go::GoObject::GoObject(Go* go)
     : go_(go) {
@@ -1006,11 +1281,17 @@ int32_t Go::GetIdFromValue(Value value) {
   return id;
 }
 
+{{end -}}
 }
 `))
 
 var specialFunctionBodies = map[string]string{
-	"cmpbody": `  return static_cast<int64_t>(mem_->Memcmp(local0_, local2_, std::min(local1_, local3_)));`,
+	"cmpbody": `  int result = mem_->Memcmp(local0_, local2_, std::min(local1_, local3_));
+  if (result == 0) {
+    // The shorter one is less when the common part is the same.
+    return static_cast<int64_t>((local1_ > local3_) - (local1_ < local3_));
+  }
+  return result < 0 ? -1 : 1;`,
 	"memcmp": `  return static_cast<int32_t>(mem_->Memcmp(local0_, local1_, local2_));`,
 	"memeqbody": `  return static_cast<int64_t>(mem_->Memcmp(local0_, local1_, local2_) == 0);`,
 	"memchr": `  return static_cast<int32_t>(mem_->Memchr(local0_, local1_, local2_));`,
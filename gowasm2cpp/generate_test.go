@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// minimalModule is a Wasm module that has one empty function main.f exported as run, and one page of memory.
+var minimalModule = Module(
+	Section(SectionType, Vec(FuncType(nil, nil))...),
+	Section(SectionImport, Vec()...),
+	Section(SectionFunction, Vec(U32(0))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+	Section(SectionExport, Vec(Export("run", KindFunction, 0), Export("mem", KindMemory, 0))...),
+	Section(SectionCode, Vec(Body())...),
+	Names("main.f"),
+)
+
+func TestGenerateWithConfigMapOutput(t *testing.T) {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    minimalModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(out[ManifestFileName], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range append(manifest.Sources, manifest.Headers...) {
+		if _, ok := out[name]; !ok {
+			t.Errorf("%s is not in the output", name)
+		}
+	}
+	if !bytes.Contains(out["inst.funcs0.cpp"], []byte("void Inst::main_2ef() {")) {
+		t.Errorf("inst.funcs0.cpp doesn't have main.f")
+	}
+}
+
+func TestGenerateWithConfigZipOutput(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewZipOutput(&buf)
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace:    "foo",
+		ModuleReader: bytes.NewReader(minimalModule),
+		Output:       out,
+		Options: Options{
+			Unity:      1,
+			Subsystems: []Subsystem{},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	for _, f := range r.File {
+		if len(f.Name) > 4 && f.Name[len(f.Name)-4:] == ".cpp" {
+			sources = append(sources, f.Name)
+		}
+	}
+	sort.Strings(sources)
+	if got, want := sources, []string{"go2cpp_all.cpp"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("sources: got: %v, want: %v", got, want)
+	}
+}
+
+func TestGenerateWithConfigCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := GenerateWithConfig(ctx, Config{
+		Module: minimalModule,
+		Output: MapOutput{},
+	}); err != context.Canceled {
+		t.Errorf("GenerateWithConfig(): got: %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestGenerateWithConfigSplitRuntime(t *testing.T) {
+	rt := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "rt",
+		Output:    rt,
+		Options: Options{
+			RuntimeOnly: true,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	prog := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace:        "foo",
+		Include:          "foo",
+		RuntimeNamespace: "rt",
+		RuntimeInclude:   "rt",
+		Module:           minimalModule,
+		Output:           prog,
+		Options: Options{
+			ProgramOnly: true,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"js.h", "js.cpp", "taskqueue.h", "bytes.h", "bits.h", "gl.h"} {
+		if _, ok := rt[name]; !ok {
+			t.Errorf("%s is not in the runtime", name)
+		}
+		if _, ok := prog[name]; ok {
+			t.Errorf("%s must not be in the program", name)
+		}
+	}
+	for _, name := range []string{"go.h", "inst.h", "mem.h", "host.h"} {
+		if _, ok := rt[name]; ok {
+			t.Errorf("%s must not be in the runtime", name)
+		}
+		if _, ok := prog[name]; !ok {
+			t.Errorf("%s is not in the program", name)
+		}
+	}
+	for _, want := range []string{`#include "rt/js.h"`, "using namespace rt;"} {
+		if !bytes.Contains(prog["go.h"], []byte(want)) {
+			t.Errorf("go.h doesn't have %q", want)
+		}
+	}
+}
+
+func TestGenerateWithConfigMaxMemory(t *testing.T) {
+	// minimalModule has one page of memory.
+	for _, tc := range []struct {
+		MaxMemory int64
+		Err       bool
+	}{
+		{MaxMemory: 0},
+		{MaxMemory: pageSize},
+		{MaxMemory: pageSize - 1, Err: true},
+		{MaxMemory: 1024, Err: true},
+	} {
+		err := GenerateWithConfig(context.Background(), Config{
+			Module:  minimalModule,
+			Output:  MapOutput{},
+			Options: Options{MaxMemory: tc.MaxMemory},
+		})
+		if tc.Err && err == nil {
+			t.Errorf("max memory %d: generating must fail", tc.MaxMemory)
+		}
+		if !tc.Err && err != nil {
+			t.Errorf("max memory %d: %v", tc.MaxMemory, err)
+		}
+	}
+}
@@ -3,47 +3,29 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
 )
 
-func writeGL(dir string, incpath string, namespace string) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "gl.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := glHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_GL_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeGL(out *output, incpath string, namespace string) error {
+	if err := out.writeFile("gl.h", glHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+	}{
+		IncludeGuard: includeGuard(namespace) + "_GL_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "gl.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := glCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("gl.cpp", glCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// isGoImportModule reports whether the import module is the module for the Go runtime and syscall/js.
+func isGoImportModule(module string) bool {
+	return module == "go" || module == "gojs"
+}
+
+// hostFunc is an imported function from a module other than the Go modules, e.g., declared by //go:wasmimport.
+// Such functions are implemented by the embedding application via the Host class.
+type hostFunc struct {
+	Module string
+	Name   string
+	Method string
+	Sig    *wasm.FunctionSig
+}
+
+// hostMethodName returns the C++ method name for the import.
+func hostMethodName(module, name string) string {
+	sanitize := func(str string) string {
+		var b strings.Builder
+		for _, r := range str {
+			if r < 0x80 && ('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+				b.WriteRune(r)
+				continue
+			}
+			b.WriteByte('_')
+		}
+		return b.String()
+	}
+	return sanitize(module) + "_" + sanitize(name)
+}
+
+func newHostFunc(module, name string, sig *wasm.FunctionSig) *hostFunc {
+	return &hostFunc{
+		Module: module,
+		Name:   name,
+		Method: hostMethodName(module, name),
+		Sig:    sig,
+	}
+}
+
+// CppDecl returns the declaration of the pure virtual method in the Host class.
+//
+// Multiple return values are returned via out-parameters ret0, ret1, ... after the arguments.
+func (h *hostFunc) CppDecl() string {
+	var args []string
+	for i, t := range h.Sig.ParamTypes {
+		args = append(args, fmt.Sprintf("%s arg%d", wasmTypeToReturnType(t).Cpp(), i))
+	}
+	ret := resultsCpp(h.Sig.ReturnTypes)
+	if ts := h.Sig.ReturnTypes; len(ts) > 1 {
+		for i, t := range ts {
+			args = append(args, fmt.Sprintf("%s* ret%d", wasmTypeToReturnType(t).Cpp(), i))
+		}
+		ret = returnTypeVoid.Cpp()
+	}
+	return fmt.Sprintf("virtual %s %s(%s) = 0;", ret, h.Method, strings.Join(args, ", "))
+}
+
+// bodyStr returns the C++ body of the imported function that calls the Host method.
+func (h *hostFunc) bodyStr() string {
+	var args []string
+	for i := range h.Sig.ParamTypes {
+		args = append(args, fmt.Sprintf("local%d_", i))
+	}
+	check := fmt.Sprintf(`  if (!go_->host_) {
+    error("no host is given for %s.%s");
+  }
+`, h.Module, h.Name)
+	switch ts := h.Sig.ReturnTypes; len(ts) {
+	case 0:
+		return check + fmt.Sprintf("  go_->host_->%s(%s);", h.Method, strings.Join(args, ", "))
+	case 1:
+		return check + fmt.Sprintf("  return go_->host_->%s(%s);", h.Method, strings.Join(args, ", "))
+	default:
+		for i := range ts {
+			args = append(args, fmt.Sprintf("&r.v%d", i))
+		}
+		return check + fmt.Sprintf(`  %s r;
+  go_->host_->%s(%s);
+  return r;`, resultsStructName(ts), h.Method, strings.Join(args, ", "))
+	}
+}
+
+func writeHost(out *output, incpath string, namespace string, hostFuncs []*hostFunc) error {
+	methods := map[string]*hostFunc{}
+	for _, h := range hostFuncs {
+		if h2, ok := methods[h.Method]; ok {
+			return fmt.Errorf("gowasm2cpp: the imported functions %s.%s and %s.%s have the same C++ name %s", h2.Module, h2.Name, h.Module, h.Name, h.Method)
+		}
+		methods[h.Method] = h
+	}
+
+	if err := out.writeFile("host.h", hostHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+		HostFuncs    []*hostFunc
+	}{
+		IncludeGuard: includeGuard(namespace) + "_HOST_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+		HostFuncs:    hostFuncs,
+	}); err != nil {
+		return err
+	}
+	if err := out.writeFile("host.cpp", hostCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+var hostHTmpl = template.Must(template.New("host.h").Parse(`// Code generated by go2cpp. DO NOT EDIT.
+
+#ifndef {{.IncludeGuard}}
+#define {{.IncludeGuard}}
+
+#include <cstdint>
+#include "{{.IncludePath}}mem.h"
+
+namespace {{.Namespace}} {
+
+class Go;
+
+// Host implements the functions imported from modules other than the Go modules,
+// e.g., the functions declared by //go:wasmimport.
+class Host {
+public:
+  virtual ~Host();
+{{range $value := .HostFuncs}}
+  // Module: {{$value.Module}}
+  // Name:   {{$value.Name}}
+  {{$value.CppDecl}}
+{{end}}
+protected:
+  // GetMem returns the linear memory of the Go program.
+  // GetMem is available while the Go program is running.
+  Mem* GetMem() const { return mem_; }
+
+private:
+  friend class Go;
+
+  Mem* mem_ = nullptr;
+};
+
+}
+
+#endif  // {{.IncludeGuard}}
+`))
+
+var hostCppTmpl = template.Must(template.New("host.cpp").Parse(`// Code generated by go2cpp. DO NOT EDIT.
+
+#include "{{.IncludePath}}host.h"
+
+namespace {{.Namespace}} {
+
+Host::~Host() = default;
+
+}
+`))
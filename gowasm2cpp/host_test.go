@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// hostModule is a Wasm module that imports env.add of (i32, i64) -> i32, and has one page of memory.
+var hostModule = Module(
+	Section(SectionType, Vec(FuncType([]byte{I32, I64}, []byte{I32}))...),
+	Section(SectionImport, Vec(ImportFunc("env", "add", 0))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+)
+
+func TestHostMethodName(t *testing.T) {
+	testCases := []struct {
+		Module string
+		Name   string
+		Want   string
+	}{
+		{"env", "add", "env_add"},
+		{"my-module", "log.write", "my_module_log_write"},
+		{"wasi_snapshot_preview1", "fd_write", "wasi_snapshot_preview1_fd_write"},
+	}
+	for _, tc := range testCases {
+		if got := hostMethodName(tc.Module, tc.Name); got != tc.Want {
+			t.Errorf("hostMethodName(%q, %q): got: %q, want: %q", tc.Module, tc.Name, got, tc.Want)
+		}
+	}
+}
+
+func TestGenerateHost(t *testing.T) {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    hostModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := out["host.h"], []byte("virtual int32_t env_add(int32_t arg0, int64_t arg1) = 0;"); !bytes.Contains(got, want) {
+		t.Errorf("host.h doesn't have %q:\n%s", want, got)
+	}
+	if got, want := out["go.cpp"], []byte("return go_->host_->env_add(local0_, local1_);"); !bytes.Contains(got, want) {
+		t.Errorf("go.cpp doesn't have %q", want)
+	}
+}
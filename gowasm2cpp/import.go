@@ -2,14 +2,216 @@
 
 package gowasm2cpp
 
-var importFuncBodies = map[string]string{
-	// func wasmExit(code int32)
-	"runtime.wasmExit": `  int32_t code = go_->mem_->LoadInt32(local0_ + 8);
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// jsABI is a revision of the ABI between GOOS=js binaries and wasm_exec.js.
+type jsABI struct {
+	// minGoVersion is the minor version of the first Go release with the ABI, e.g., 21 for go1.21.
+	minGoVersion int
+
+	// module is the import module of the runtime and syscall/js functions.
+	module string
+
+	// bodies is the C++ bodies of the imported functions.
+	bodies map[string]string
+}
+
+// jsLayout is the offsets from sp in the stack frames of the imported functions, which wasm_exec.js hardcodes for each
+// Go release.
+//
+// jsLayout has the offsets of the code of runtime.wasmExit and the results that syscall/js functions store after
+// calling back into Go. The other offsets are the same in all the known revisions. See importFuncBodies. A revision
+// with different offsets has its own jsLayout.
+type jsLayout struct {
+	// wasmExitCode is the offset of the code argument of runtime.wasmExit.
+	wasmExitCode int
+
+	// valueCallResult and valueCallOK are the offsets of the results of syscall/js.valueCall.
+	valueCallResult int
+	valueCallOK     int
+
+	// valueInvokeResult and valueInvokeOK are the offsets of the results of syscall/js.valueInvoke and
+	// syscall/js.valueNew, which have the same signature.
+	valueInvokeResult int
+	valueInvokeOK     int
+}
+
+// jsLayout114 is the stack layout of wasm_exec.js from Go 1.14 to Go 1.21, where a ref is 8 bytes.
+var jsLayout114 = &jsLayout{
+	wasmExitCode:      8,
+	valueCallResult:   56,
+	valueCallOK:       64,
+	valueInvokeResult: 40,
+	valueInvokeOK:     48,
+}
+
+// maxGoVersion is the minor version of the latest Go release that is tested. Newer versions are rejected as their ABIs
+// might be different.
+const maxGoVersion = 21
+
+// jsABIs is the known ABI revisions in ascending order of Go versions.
+var jsABIs = []*jsABI{
+	{
+		minGoVersion: 14,
+		module:       "go",
+		bodies: withImportFuncBodies(jsLayout114, map[string]string{
+			// func walltime1() (sec int64, nsec int32)
+			"runtime.walltime1": walltimeBody,
+		}),
+	},
+	{
+		// Go 1.17 renamed runtime.walltime1 to runtime.walltime.
+		minGoVersion: 17,
+		module:       "go",
+		bodies: withImportFuncBodies(jsLayout114, map[string]string{
+			// func walltime() (sec int64, nsec int32)
+			"runtime.walltime": walltimeBody,
+		}),
+	},
+	{
+		// Go 1.21 renamed the import module to gojs.
+		minGoVersion: 21,
+		module:       "gojs",
+		bodies: withImportFuncBodies(jsLayout114, map[string]string{
+			// func walltime() (sec int64, nsec int32)
+			"runtime.walltime": walltimeBody,
+		}),
+	},
+}
+
+// withImportFuncBodies returns a new map of importFuncBodies, the bodies for layout, and bodies.
+func withImportFuncBodies(layout *jsLayout, bodies map[string]string) map[string]string {
+	m := map[string]string{}
+	for name, body := range importFuncBodies {
+		m[name] = body
+	}
+	for name, body := range layout.bodies() {
+		m[name] = body
+	}
+	for name, body := range bodies {
+		m[name] = body
+	}
+	return m
+}
+
+// goMinorVersion returns the minor version of the Go version like "go1.21.3", or -1 if version is not a Go 1 version.
+func goMinorVersion(version string) int {
+	if !strings.HasPrefix(version, "go1.") {
+		return -1
+	}
+	v := version[len("go1."):]
+	if i := strings.IndexFunc(v, func(r rune) bool { return r < '0' || '9' < r }); i >= 0 {
+		v = v[:i]
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// selectJSABI returns the ABI revision for the Go runtime and syscall/js imports of mod.
+// selectJSABI returns nil if mod imports no such functions, e.g., for GOOS=wasip1.
+//
+// The Go version is detected from the producers section or runtime.buildVersion in the data segments. If the version
+// is not found, selectJSABI returns an error, as the stack layout cannot be checked.
+func selectJSABI(mod *wasm.Module) (*jsABI, error) {
+	var module string
+	for _, e := range mod.Imports {
+		if e.Kind != wasm.ExternalFunction || !isGoImportModule(e.ModuleName) {
+			continue
+		}
+		if module != "" && module != e.ModuleName {
+			return nil, fmt.Errorf("gowasm2cpp: the module imports from both %q and %q", module, e.ModuleName)
+		}
+		module = e.ModuleName
+	}
+	if module == "" {
+		return nil, nil
+	}
+
+	version := goVersion(mod)
+	if version == "" {
+		return nil, fmt.Errorf("gowasm2cpp: the Go version of the module cannot be detected from the producers section or runtime.buildVersion")
+	}
+
+	minor := goMinorVersion(version)
+	if minor < 0 {
+		return nil, fmt.Errorf("gowasm2cpp: unknown Go version: %s", version)
+	}
+	if minor < jsABIs[0].minGoVersion {
+		return nil, fmt.Errorf("gowasm2cpp: Go version %s is not supported: go1.%d or later is required", version, jsABIs[0].minGoVersion)
+	}
+	if minor > maxGoVersion {
+		return nil, fmt.Errorf("gowasm2cpp: unknown Go version %s: the latest known version is go1.%d", version, maxGoVersion)
+	}
+	var abi *jsABI
+	for _, a := range jsABIs {
+		if a.minGoVersion <= minor {
+			abi = a
+		}
+	}
+	if abi.module != module {
+		return nil, fmt.Errorf("gowasm2cpp: Go version %s must import from %q but the module imports from %q", version, abi.module, module)
+	}
+	return abi, nil
+}
+
+// walltimeBody is the body of runtime.walltime, which is named runtime.walltime1 before Go 1.17.
+const walltimeBody = `  double now = go_->UnixNowInMilliseconds();
+  go_->mem_->StoreInt64(local0_ + 8, static_cast<int64_t>(now / 1000));
+  go_->mem_->StoreInt32(local0_ + 16, static_cast<int32_t>(std::fmod(now, 1000) * 1000000));`
+
+// bodies returns the C++ bodies of the imported functions that depend on l.
+func (l *jsLayout) bodies() map[string]string {
+	return map[string]string{
+		// func wasmExit(code int32)
+		"runtime.wasmExit": fmt.Sprintf(`  int32_t code = go_->mem_->LoadInt32(local0_ + %d);
   go_->exited_ = true;
   // wasm_exec.js resets the members here, but do not reset members here.
   // Resetting them causes use-after-free. This can be detected by the address sanitizer.
-  go_->Exit(code);`,
+  go_->Exit(code);`, l.wasmExitCode),
+
+		// func valueCall(v ref, m string, args []ref) (ref, bool)
+		"syscall/js.valueCall": fmt.Sprintf(`  Value v = go_->LoadValue(local0_ + 8);
+  Value m = Value::ReflectGet(v, go_->mem_->LoadString(local0_ + 16));
+  std::vector<Value> args = go_->LoadSliceOfValues(local0_ + 32);
+  Value result = Value::ReflectApply(m, v, args);
+  local0_ = go_->inst_->getsp();
+  go_->StoreValue(local0_ + %d, result);
+  go_->mem_->StoreInt8(local0_ + %d, 1);`, l.valueCallResult, l.valueCallOK),
 
+		// func valueInvoke(v ref, args []ref) (ref, bool)
+		"syscall/js.valueInvoke": fmt.Sprintf(`  Value v = go_->LoadValue(local0_ + 8);
+  std::vector<Value> args = go_->LoadSliceOfValues(local0_ + 16);
+  Value result = Value::ReflectApply(v, Value{}, args);
+  local0_ = go_->inst_->getsp();
+  go_->StoreValue(local0_ + %d, result);
+  go_->mem_->StoreInt8(local0_ + %d, 1);`, l.valueInvokeResult, l.valueInvokeOK),
+
+		// func valueNew(v ref, args []ref) (ref, bool)
+		"syscall/js.valueNew": fmt.Sprintf(`  Value v = go_->LoadValue(local0_ + 8);
+  std::vector<Value> args = go_->LoadSliceOfValues(local0_ + 16);
+  Value result = Value::ReflectConstruct(v, args);
+  if (!result.IsUndefined()) {
+    local0_ = go_->inst_->getsp();
+    go_->StoreValue(local0_ + %[1]d, result);
+    go_->mem_->StoreInt8(local0_ + %[2]d, 1);
+  } else {
+    go_->StoreValue(local0_ + %[1]d, Value{});
+    go_->mem_->StoreInt8(local0_ + %[2]d, 0);
+  }`, l.valueInvokeResult, l.valueInvokeOK),
+	}
+}
+
+// importFuncBodies is the C++ bodies of the imported functions common to all the ABI revisions.
+var importFuncBodies = map[string]string{
 	// func wasmWrite(fd uintptr, p unsafe.Pointer, n int32)
 	"runtime.wasmWrite": `  int64_t fd = go_->mem_->LoadInt64(local0_ + 8);
   if (fd != 1 && fd != 2) {
@@ -28,11 +230,6 @@ var importFuncBodies = map[string]string{
 	// func nanotime1() int64
 	"runtime.nanotime1": `  go_->mem_->StoreInt64(local0_ + 8, go_->PreciseNowInNanoseconds());`,
 
-	// func walltime1() (sec int64, nsec int32)
-	"runtime.walltime1": `  double now = go_->UnixNowInMilliseconds();
-  go_->mem_->StoreInt64(local0_ + 8, static_cast<int64_t>(now / 1000));
-  go_->mem_->StoreInt32(local0_ + 16, static_cast<int32_t>(std::fmod(now, 1000) * 1000000));`,
-
 	// func scheduleTimeoutEvent(delay int64) int32
 	"runtime.scheduleTimeoutEvent": `  int64_t interval = go_->mem_->LoadInt64(local0_ + 8);
   int32_t id = go_->SetTimeout(static_cast<double>(interval));
@@ -77,36 +274,6 @@ var importFuncBodies = map[string]string{
 	// valueSetIndex(v ref, i int, x ref)
 	"syscall/js.valueSetIndex": `  Value::ReflectSet(go_->LoadValue(local0_ + 8), std::to_string(go_->mem_->LoadInt64(local0_ + 16)), go_->LoadValue(local0_ + 24));`,
 
-	// func valueCall(v ref, m string, args []ref) (ref, bool)
-	"syscall/js.valueCall": `  Value v = go_->LoadValue(local0_ + 8);
-  Value m = Value::ReflectGet(v, go_->mem_->LoadString(local0_ + 16));
-  std::vector<Value> args = go_->LoadSliceOfValues(local0_ + 32);
-  Value result = Value::ReflectApply(m, v, args);
-  local0_ = go_->inst_->getsp();
-  go_->StoreValue(local0_ + 56, result);
-  go_->mem_->StoreInt8(local0_ + 64, 1);`,
-
-	// func valueInvoke(v ref, args []ref) (ref, bool)
-	"syscall/js.valueInvoke": `  Value v = go_->LoadValue(local0_ + 8);
-  std::vector<Value> args = go_->LoadSliceOfValues(local0_ + 16);
-  Value result = Value::ReflectApply(v, Value{}, args);
-  local0_ = go_->inst_->getsp();
-  go_->StoreValue(local0_ + 40, result);
-  go_->mem_->StoreInt8(local0_ + 48, 1);`,
-
-	// func valueNew(v ref, args []ref) (ref, bool)
-	"syscall/js.valueNew": `  Value v = go_->LoadValue(local0_ + 8);
-  std::vector<Value> args = go_->LoadSliceOfValues(local0_ + 16);
-  Value result = Value::ReflectConstruct(v, args);
-  if (!result.IsUndefined()) {
-    local0_ = go_->inst_->getsp();
-    go_->StoreValue(local0_ + 40, result);
-    go_->mem_->StoreInt8(local0_ + 48, 1);
-  } else {
-    go_->StoreValue(local0_ + 40, Value{});
-    go_->mem_->StoreInt8(local0_ + 48, 0);
-  }`,
-
 	// func valueLength(v ref) int
 	"syscall/js.valueLength": `  go_->mem_->StoreInt64(local0_ + 16, static_cast<int64_t>(go_->LoadValue(local0_ + 8).ToArray().size()));`,
 
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// gojsModule is a Wasm module that imports gojs.runtime.walltime of (i32), and has one page of memory with the string
+// variable "go1.21.0" at 0, i.e., the header of the string at 0 and the data at 16.
+var gojsModule = Module(
+	Section(SectionType, Vec(FuncType([]byte{I32}, nil))...),
+	Section(SectionImport, Vec(ImportFunc("gojs", "runtime.walltime", 0))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+	Section(SectionData, Vec(Data(0, append([]byte{
+		// The pointer to the data and the length.
+		0x10, 0, 0, 0, 0, 0, 0, 0,
+		0x08, 0, 0, 0, 0, 0, 0, 0,
+	}, "go1.21.0"...)))...),
+)
+
+func TestGoMinorVersion(t *testing.T) {
+	testCases := []struct {
+		In   string
+		Want int
+	}{
+		{"go1.14", 14},
+		{"go1.21.3", 21},
+		{"go1.22rc1", 22},
+		{"go1.9beta2", 9},
+		{"go2.0", -1},
+		{"go1.", -1},
+		{"", -1},
+	}
+	for _, tc := range testCases {
+		if got := goMinorVersion(tc.In); got != tc.Want {
+			t.Errorf("goMinorVersion(%q): got: %d, want: %d", tc.In, got, tc.Want)
+		}
+	}
+}
+
+func TestSelectJSABI(t *testing.T) {
+	imports := func(module string, names ...string) []wasm.Import {
+		var is []wasm.Import
+		for _, n := range names {
+			is = append(is, wasm.Import{ModuleName: module, FieldName: n, Kind: wasm.ExternalFunction})
+		}
+		return is
+	}
+	data := func(version string) []wasm.Data {
+		return []wasm.Data{stringVarData(0, "", version)}
+	}
+
+	testCases := []struct {
+		Name    string
+		Module  *wasm.Module
+		Want    int
+		WantNil bool
+		Err     bool
+	}{
+		{
+			Name:    "no Go imports",
+			Module:  &wasm.Module{Imports: imports("env", "f")},
+			WantNil: true,
+		},
+		{
+			Name:   "go1.16",
+			Module: &wasm.Module{Imports: imports("go", "runtime.walltime1"), Data: data("go1.16.15")},
+			Want:   14,
+		},
+		{
+			Name:   "go1.20",
+			Module: &wasm.Module{Imports: imports("go", "runtime.walltime"), Data: data("go1.20")},
+			Want:   17,
+		},
+		{
+			Name:   "go1.21",
+			Module: &wasm.Module{Imports: imports("gojs", "runtime.walltime"), Data: data("go1.21.13")},
+			Want:   21,
+		},
+		{
+			Name: "string literal before go1.16",
+			Module: &wasm.Module{
+				Imports: imports("go", "runtime.walltime1"),
+				Data:    []wasm.Data{stringVarData(0, "go1.13", "go1.16.15")},
+			},
+			Want: 14,
+		},
+		{
+			Name: "string literal before go1.21",
+			Module: &wasm.Module{
+				Imports: imports("gojs", "runtime.walltime"),
+				Data:    []wasm.Data{stringVarData(0, "go1.99.0", "go1.21.0")},
+			},
+			Want: 21,
+		},
+		{
+			Name: "string literal in another segment before go1.20",
+			Module: &wasm.Module{
+				Imports: imports("go", "runtime.walltime"),
+				Data: []wasm.Data{
+					{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("\x00go1.16\x00")},
+					stringVarData(16, "", "go1.20"),
+				},
+			},
+			Want: 17,
+		},
+		{
+			// The version is not detected as two string variables look like Go versions.
+			Name: "ambiguous versions",
+			Module: &wasm.Module{
+				Imports: imports("go", "runtime.walltime"),
+				Data: []wasm.Data{
+					stringVarData(0, "", "go1.13"),
+					stringVarData(32, "", "go1.20"),
+				},
+			},
+			Err: true,
+		},
+		{
+			Name:   "unknown version",
+			Module: &wasm.Module{Imports: imports("gojs", "runtime.wasmExit")},
+			Err:    true,
+		},
+		{
+			Name:   "too old",
+			Module: &wasm.Module{Imports: imports("go", "runtime.walltime"), Data: data("go1.13.8")},
+			Err:    true,
+		},
+		{
+			Name:   "too new",
+			Module: &wasm.Module{Imports: imports("gojs", "runtime.walltime"), Data: data("go1.22rc1")},
+			Err:    true,
+		},
+		{
+			Name:   "module mismatch",
+			Module: &wasm.Module{Imports: imports("go", "runtime.walltime"), Data: data("go1.21.0")},
+			Err:    true,
+		},
+		{
+			Name:   "both modules",
+			Module: &wasm.Module{Imports: append(imports("go", "debug"), imports("gojs", "debug")...)},
+			Err:    true,
+		},
+	}
+	for _, tc := range testCases {
+		abi, err := selectJSABI(tc.Module)
+		if tc.Err {
+			if err == nil {
+				t.Errorf("%s: selectJSABI must fail", tc.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		if tc.WantNil {
+			if abi != nil {
+				t.Errorf("%s: got: go1.%d, want: nil", tc.Name, abi.minGoVersion)
+			}
+			continue
+		}
+		if abi == nil {
+			t.Errorf("%s: got: nil, want: go1.%d", tc.Name, tc.Want)
+			continue
+		}
+		if got, want := abi.minGoVersion, tc.Want; got != want {
+			t.Errorf("%s: got: go1.%d, want: go1.%d", tc.Name, got, want)
+		}
+	}
+}
+
+func TestGenerateJSABI(t *testing.T) {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    gojsModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out["go.cpp"], []byte("go_->UnixNowInMilliseconds()"); !bytes.Contains(got, want) {
+		t.Errorf("go.cpp doesn't have %q", want)
+	}
+
+	for _, v := range []string{"go1.16.0", "go1.99.0"} {
+		m := bytes.Replace(gojsModule, []byte("go1.21.0"), []byte(v), 1)
+		if err := GenerateWithConfig(context.Background(), Config{
+			Namespace: "foo",
+			Module:    m,
+			Output:    MapOutput{},
+		}); err == nil {
+			t.Errorf("%s: generating must fail", v)
+		}
+	}
+}
+
+func TestJSLayoutBodies(t *testing.T) {
+	// The offsets are from wasm_exec.js of each release.
+	testCases := []struct {
+		Name string
+		Want string
+	}{
+		{"runtime.wasmExit", "LoadInt32(local0_ + 8)"},
+		{"syscall/js.valueCall", "StoreValue(local0_ + 56, result)"},
+		{"syscall/js.valueCall", "StoreInt8(local0_ + 64, 1)"},
+		{"syscall/js.valueInvoke", "StoreValue(local0_ + 40, result)"},
+		{"syscall/js.valueNew", "StoreInt8(local0_ + 48, 0)"},
+	}
+	for _, abi := range jsABIs {
+		for _, tc := range testCases {
+			if got := abi.bodies[tc.Name]; !strings.Contains(got, tc.Want) {
+				t.Errorf("go1.%d: %s doesn't have %q:\n%s", abi.minGoVersion, tc.Name, tc.Want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// evalInitExpr evaluates the constant expression expr and returns the type and the bits of the value.
+// Floating point values are returned as their IEEE 754 bits so that NaN payloads are kept.
+//
+// globals is the globals defined so far, which global.get can refer to.
+func evalInitExpr(expr []byte, globals []*wasmGlobal) (wasm.ValueType, uint64, error) {
+	r := bytes.NewReader(expr)
+
+	var t wasm.ValueType
+	var v uint64
+	var n int
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, fmt.Errorf("gowasm2cpp: unterminated constant expression")
+		}
+		if op == 0x0b {
+			break
+		}
+		n++
+		switch op {
+		case 0x41: // i32.const
+			x, err := readVarint(r, 32)
+			if err != nil {
+				return 0, 0, err
+			}
+			t, v = wasm.ValueTypeI32, uint64(uint32(int32(x)))
+		case 0x42: // i64.const
+			x, err := readVarint(r, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			t, v = wasm.ValueTypeI64, uint64(x)
+		case 0x43: // f32.const
+			var b [4]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return 0, 0, err
+			}
+			t, v = wasm.ValueTypeF32, uint64(binary.LittleEndian.Uint32(b[:]))
+		case 0x44: // f64.const
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return 0, 0, err
+			}
+			t, v = wasm.ValueTypeF64, binary.LittleEndian.Uint64(b[:])
+		case 0x23: // global.get
+			idx, err := binary.ReadUvarint(r)
+			if err != nil {
+				return 0, 0, err
+			}
+			if idx >= uint64(len(globals)) {
+				return 0, 0, fmt.Errorf("gowasm2cpp: global index out of range in constant expression: %d", idx)
+			}
+			g := globals[idx]
+			t, v = g.Type, g.Init
+		default:
+			return 0, 0, fmt.Errorf("gowasm2cpp: unsupported instruction in constant expression: 0x%02x", op)
+		}
+	}
+	if n != 1 {
+		return 0, 0, fmt.Errorf("gowasm2cpp: a constant expression must have exactly one instruction but %d", n)
+	}
+	return t, v, nil
+}
+
+// evalI32InitExpr evaluates the constant expression expr of i32, e.g., an offset of a data or element segment.
+func evalI32InitExpr(expr []byte, globals []*wasmGlobal) (int32, error) {
+	t, v, err := evalInitExpr(expr, globals)
+	if err != nil {
+		return 0, err
+	}
+	if t != wasm.ValueTypeI32 {
+		return 0, fmt.Errorf("gowasm2cpp: constant expression must be i32 but %s", valueTypeNames[t])
+	}
+	return int32(uint32(v)), nil
+}
+
+// readVarint reads a signed LEB128 integer of the given bit size.
+func readVarint(r io.ByteReader, size uint) (int64, error) {
+	var x int64
+	var shift uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= size {
+			return 0, fmt.Errorf("gowasm2cpp: too long LEB128 integer")
+		}
+		x |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				x |= -1 << shift
+			}
+			return x, nil
+		}
+	}
+}
+
+// cppConstant returns the C++ expression of the value of type t with the bits v.
+// constexpr reports whether the expression is a constant expression in C++.
+func cppConstant(t wasm.ValueType, v uint64) (expr string, constexpr bool) {
+	switch t {
+	case wasm.ValueTypeI32:
+		x := int32(uint32(v))
+		if x == math.MinInt32 {
+			return "(-2147483647 - 1)", true
+		}
+		return strconv.FormatInt(int64(x), 10), true
+	case wasm.ValueTypeI64:
+		x := int64(v)
+		if x == math.MinInt64 {
+			return "(-9223372036854775807LL - 1)", true
+		}
+		return strconv.FormatInt(x, 10) + "LL", true
+	case wasm.ValueTypeF32:
+		bits := uint32(v)
+		f := math.Float32frombits(bits)
+		switch {
+		case math.IsInf(float64(f), 1):
+			return "std::numeric_limits<float>::infinity()", true
+		case math.IsInf(float64(f), -1):
+			return "-std::numeric_limits<float>::infinity()", true
+		case bits == 0x7fc00000:
+			return "std::numeric_limits<float>::quiet_NaN()", true
+		case bits == 0xffc00000:
+			return "-std::numeric_limits<float>::quiet_NaN()", true
+		case f != f:
+			// C++14 cannot make other NaNs in constant expressions.
+			return fmt.Sprintf("Inst::FloatFromBits(%du)", bits), false
+		}
+		return floatLiteral(strconv.FormatFloat(float64(f), 'g', -1, 32)) + "f", true
+	case wasm.ValueTypeF64:
+		f := math.Float64frombits(v)
+		switch {
+		case math.IsInf(f, 1):
+			return "std::numeric_limits<double>::infinity()", true
+		case math.IsInf(f, -1):
+			return "-std::numeric_limits<double>::infinity()", true
+		case v == 0x7ff8000000000000:
+			return "std::numeric_limits<double>::quiet_NaN()", true
+		case v == 0xfff8000000000000:
+			return "-std::numeric_limits<double>::quiet_NaN()", true
+		case f != f:
+			return fmt.Sprintf("Inst::DoubleFromBits(%dull)", v), false
+		}
+		return floatLiteral(strconv.FormatFloat(f, 'g', -1, 64)), true
+	default:
+		panic(fmt.Sprintf("gowasm2cpp: unexpected value type: %d", t))
+	}
+}
+
+// floatLiteral makes str a C++ floating point literal by adding a fractional part if needed.
+func floatLiteral(str string) string {
+	for _, c := range str {
+		if c == '.' || c == 'e' {
+			return str
+		}
+	}
+	return str + ".0"
+}
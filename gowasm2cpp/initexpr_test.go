@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+func TestEvalInitExpr(t *testing.T) {
+	globals := []*wasmGlobal{
+		{Type: wasm.ValueTypeI64, Index: 0, Init: 42},
+	}
+	testCases := []struct {
+		Expr []byte
+		Type wasm.ValueType
+		Bits uint64
+	}{
+		{[]byte{0x41, 0x80, 0x20, 0x0b}, wasm.ValueTypeI32, 4096},
+		{[]byte{0x41, 0x7f, 0x0b}, wasm.ValueTypeI32, 0xffffffff},
+		{[]byte{0x42, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f, 0x0b}, wasm.ValueTypeI64, 1 << 63},
+		{[]byte{0x43, 0x01, 0x00, 0xa0, 0x7f, 0x0b}, wasm.ValueTypeF32, 0x7fa00001},
+		{[]byte{0x44, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f, 0x0b}, wasm.ValueTypeF64, 0x3ff8000000000000},
+		{[]byte{0x23, 0x00, 0x0b}, wasm.ValueTypeI64, 42},
+	}
+	for _, tc := range testCases {
+		gotType, gotBits, err := evalInitExpr(tc.Expr, globals)
+		if err != nil {
+			t.Errorf("evalInitExpr(%v): %v", tc.Expr, err)
+			continue
+		}
+		if gotType != tc.Type || gotBits != tc.Bits {
+			t.Errorf("evalInitExpr(%v): got: %v, 0x%x, want: %v, 0x%x", tc.Expr, gotType, gotBits, tc.Type, tc.Bits)
+		}
+	}
+
+	for _, expr := range [][]byte{
+		{0x41, 0x00},
+		{0x0b},
+		{0x41, 0x00, 0x41, 0x00, 0x0b},
+		{0x23, 0x01, 0x0b},
+		{0x6a, 0x0b},
+	} {
+		if _, _, err := evalInitExpr(expr, globals); err == nil {
+			t.Errorf("evalInitExpr(%v) must return an error", expr)
+		}
+	}
+}
+
+func TestCppConstant(t *testing.T) {
+	testCases := []struct {
+		Type      wasm.ValueType
+		Bits      uint64
+		Expr      string
+		Constexpr bool
+	}{
+		{wasm.ValueTypeI32, 0xffffffff, "-1", true},
+		{wasm.ValueTypeI32, 0x80000000, "(-2147483647 - 1)", true},
+		{wasm.ValueTypeI64, 1 << 63, "(-9223372036854775807LL - 1)", true},
+		{wasm.ValueTypeF32, 0x40400000, "3.0f", true},
+		{wasm.ValueTypeF32, 0x7fc00000, "std::numeric_limits<float>::quiet_NaN()", true},
+		{wasm.ValueTypeF32, 0x7fa00001, "Inst::FloatFromBits(2141192193u)", false},
+		{wasm.ValueTypeF64, 0x3ff8000000000000, "1.5", true},
+		{wasm.ValueTypeF64, 0x8000000000000000, "-0.0", true},
+		{wasm.ValueTypeF64, 0x7e37e43c8800759c, "1e+300", true},
+		{wasm.ValueTypeF64, 0xfff0000000000000, "-std::numeric_limits<double>::infinity()", true},
+	}
+	for _, tc := range testCases {
+		gotExpr, gotConstexpr := cppConstant(tc.Type, tc.Bits)
+		if gotExpr != tc.Expr || gotConstexpr != tc.Constexpr {
+			t.Errorf("cppConstant(%v, 0x%x): got: %q, %t, want: %q, %t", tc.Type, tc.Bits, gotExpr, gotConstexpr, tc.Expr, tc.Constexpr)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// DefaultReportBiggestFuncs is the default number of the biggest functions in a report.
+const DefaultReportBiggestFuncs = 10
+
+// Report is a compatibility report of a Wasm module.
+type Report struct {
+	GoVersion string `json:"go_version,omitempty"`
+
+	NumImports int `json:"num_imports"`
+	NumFuncs   int `json:"num_funcs"`
+
+	// MissingImports is the imported functions that have no C++ implementations.
+	MissingImports []ManifestImport `json:"missing_imports"`
+
+	// UnsupportedFuncs is the functions that cannot be converted to C++.
+	UnsupportedFuncs []ReportFunc `json:"unsupported_funcs"`
+
+	// BiggestFuncs is the biggest functions in the size of the Wasm code.
+	BiggestFuncs []ReportFunc `json:"biggest_funcs"`
+
+	// RemovedFuncs is the number of the functions that are unreachable and not generated.
+	RemovedFuncs int `json:"removed_funcs"`
+
+	// RemovedCodeSize is the total size of the Wasm code of the removed functions in bytes.
+	RemovedCodeSize int `json:"removed_code_size"`
+
+	NumDataSegments int `json:"num_data_segments"`
+	DataSize        int `json:"data_size"`
+	TableSize       int `json:"table_size"`
+}
+
+// ReportFunc represents a function in a report.
+type ReportFunc struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+
+	// Size is the size of the Wasm code in bytes.
+	Size int `json:"size"`
+
+	// Reason is the reason why the function cannot be converted.
+	Reason string `json:"reason,omitempty"`
+}
+
+// OK reports whether the Wasm module can be converted to C++ without known problems.
+func (r *Report) OK() bool {
+	return len(r.MissingImports) == 0 && len(r.UnsupportedFuncs) == 0
+}
+
+// WriteText writes the human-readable report to w.
+func (r *Report) WriteText(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+	}
+
+	if r.GoVersion != "" {
+		printf("Go version:    %s\n", r.GoVersion)
+	} else {
+		printf("Go version:    unknown\n")
+	}
+	printf("Functions:     %d (imports: %d)\n", r.NumFuncs, r.NumImports)
+	printf("Unreachable:   %d (%d bytes)\n", r.RemovedFuncs, r.RemovedCodeSize)
+	printf("Data segments: %d (%d bytes)\n", r.NumDataSegments, r.DataSize)
+	printf("Table size:    %d\n", r.TableSize)
+
+	printf("\nImports without implementations: %d\n", len(r.MissingImports))
+	for _, i := range r.MissingImports {
+		printf("  %s.%s\n", i.Module, i.Name)
+	}
+
+	printf("\nUnsupported functions: %d\n", len(r.UnsupportedFuncs))
+	for _, f := range r.UnsupportedFuncs {
+		printf("  %s (index: %d): %s\n", f.Name, f.Index, f.Reason)
+	}
+
+	printf("\nBiggest functions:\n")
+	for _, f := range r.BiggestFuncs {
+		printf("  %8d bytes  %s (index: %d)\n", f.Size, f.Name, f.Index)
+	}
+	return err
+}
+
+// Inspect inspects the Wasm file and reports the problems to convert it to C++.
+// If options is nil, the default options are used. Only the function bodies and KeepUnreachable in options are used.
+//
+// Inspect reports at most biggestFuncs biggest functions.
+func Inspect(wasmFile string, options *Options, biggestFuncs int) (*Report, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	bin, err := ioutil.ReadFile(wasmFile)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := options.registry()
+	if err != nil {
+		return nil, err
+	}
+	m, err := newModule(bin, registry, options.Overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		GoVersion:        goVersion(m.mod),
+		NumImports:       len(m.importFuncs),
+		NumFuncs:         m.numFuncs(),
+		MissingImports:   []ManifestImport{},
+		UnsupportedFuncs: []ReportFunc{},
+		BiggestFuncs:     []ReportFunc{},
+		NumDataSegments:  len(m.data),
+	}
+	if !options.KeepUnreachable {
+		r.RemovedFuncs, r.RemovedCodeSize = m.removeUnreachableFuncs()
+	}
+
+	for i, f := range m.importFuncs {
+		if f.BodyStr != "" {
+			continue
+		}
+		e := m.mod.Imports[i]
+		r.MissingImports = append(r.MissingImports, ManifestImport{
+			Module: e.ModuleName,
+			Name:   e.FieldName,
+		})
+	}
+
+	for _, f := range m.funcs {
+		rf := ReportFunc{
+			Name:  f.Wasm.Name,
+			Index: f.Index,
+			Size:  len(m.mod.Code[f.Index-len(m.importFuncs)].Code),
+		}
+		r.BiggestFuncs = append(r.BiggestFuncs, rf)
+
+		if err := f.checkCppImpl(); err != nil {
+			var ferr *FuncError
+			if errors.As(err, &ferr) {
+				err = ferr.Err
+			}
+			rf.Reason = err.Error()
+			r.UnsupportedFuncs = append(r.UnsupportedFuncs, rf)
+		}
+	}
+	sort.SliceStable(r.BiggestFuncs, func(i, j int) bool {
+		return r.BiggestFuncs[i].Size > r.BiggestFuncs[j].Size
+	})
+	if len(r.BiggestFuncs) > biggestFuncs {
+		r.BiggestFuncs = r.BiggestFuncs[:biggestFuncs]
+	}
+
+	for _, d := range m.data {
+		r.DataSize += len(d.Data)
+	}
+	for _, t := range m.tables {
+		r.TableSize += len(t)
+	}
+
+	return r, nil
+}
+
+// checkCppImpl reports an error if f cannot be converted to C++.
+func (f *wasmFunc) checkCppImpl() error {
+	_, err := f.CppImpl("Inst", "")
+	return err
+}
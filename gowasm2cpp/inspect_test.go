@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// inspectModule is a Wasm module that has these functions of () -> ():
+//
+//   - main.run, exported as run.
+//   - main.bad, exported as bad, has memory.init, which is not supported.
+//   - main.dead, not referred, has two nops.
+var inspectModule = Module(
+	Section(SectionType, Vec(FuncType(nil, nil))...),
+	Section(SectionFunction, Vec(U32(0), U32(0), U32(0))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+	Section(SectionExport, Vec(Export("run", KindFunction, 0), Export("bad", KindFunction, 1))...),
+	Section(SectionCode, Vec(
+		Body(),
+		// memory.init 0 0
+		Body(0xfc, 0x08, 0x00, 0x00),
+		// nop; nop
+		Body(0x01, 0x01),
+	)...),
+	Names("main.run", "main.bad", "main.dead"),
+)
+
+func inspectBytes(t *testing.T, bin []byte, options *Options) *Report {
+	dir, err := ioutil.TempDir("", "gowasm2cpp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "main.wasm")
+	if err := ioutil.WriteFile(path, bin, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Inspect(path, options, DefaultReportBiggestFuncs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestInspect(t *testing.T) {
+	r := inspectBytes(t, inspectModule, nil)
+
+	if r.OK() {
+		t.Errorf("OK(): got: true, want: false")
+	}
+	if got, want := r.NumFuncs, 3; got != want {
+		t.Errorf("NumFuncs: got: %d, want: %d", got, want)
+	}
+	// main.bad cannot be disassembled and might call any function, so no functions are removed.
+	if got, want := r.RemovedFuncs, 0; got != want {
+		t.Errorf("RemovedFuncs: got: %d, want: %d", got, want)
+	}
+	if got, want := len(r.MissingImports), 0; got != want {
+		t.Errorf("len(MissingImports): got: %d, want: %d", got, want)
+	}
+
+	if got, want := len(r.UnsupportedFuncs), 1; got != want {
+		t.Fatalf("len(UnsupportedFuncs): got: %d, want: %d", got, want)
+	}
+	f := r.UnsupportedFuncs[0]
+	if got, want := f.Name, "main.bad"; got != want {
+		t.Errorf("UnsupportedFuncs[0].Name: got: %q, want: %q", got, want)
+	}
+	if got, want := f.Index, 1; got != want {
+		t.Errorf("UnsupportedFuncs[0].Index: got: %d, want: %d", got, want)
+	}
+	if !strings.Contains(f.Reason, "unknown opcode") {
+		t.Errorf("UnsupportedFuncs[0].Reason: got: %q, want: containing %q", f.Reason, "unknown opcode")
+	}
+
+	var names []string
+	for _, f := range r.BiggestFuncs {
+		names = append(names, f.Name)
+	}
+	if got, want := strings.Join(names, ","), "main.bad,main.dead,main.run"; got != want {
+		t.Errorf("BiggestFuncs: got: %s, want: %s", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Unsupported functions: 1", "  main.bad (index: 1): "} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("WriteText() doesn't have %q:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestInspectUnreachable(t *testing.T) {
+	r := inspectBytes(t, reachModule, nil)
+	if !r.OK() {
+		t.Errorf("OK(): got: false, want: true: %+v", r)
+	}
+	if got, want := r.RemovedFuncs, 1; got != want {
+		t.Errorf("RemovedFuncs: got: %d, want: %d", got, want)
+	}
+	if got, want := r.RemovedCodeSize, 2; got != want {
+		t.Errorf("RemovedCodeSize: got: %d, want: %d", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := "Unreachable:   1 (2 bytes)"; !strings.Contains(buf.String(), s) {
+		t.Errorf("WriteText() doesn't have %q:\n%s", s, buf.String())
+	}
+}
+
+func TestInspectKeepUnreachable(t *testing.T) {
+	r := inspectBytes(t, reachModule, &Options{KeepUnreachable: true})
+	if got, want := r.RemovedFuncs, 0; got != want {
+		t.Errorf("RemovedFuncs: got: %d, want: %d", got, want)
+	}
+	if got, want := len(r.BiggestFuncs), 4; got != want {
+		t.Errorf("len(BiggestFuncs): got: %d, want: %d", got, want)
+	}
+}
+
+func TestInspectOK(t *testing.T) {
+	r := inspectBytes(t, minimalModule, nil)
+	if !r.OK() {
+		t.Errorf("OK(): got: false, want: true: %+v", r)
+	}
+}
@@ -3,39 +3,21 @@
 package gowasm2cpp
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
 	"text/template"
 
 	"golang.org/x/sync/errgroup"
 )
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func writeInst(dir string, incpath string, namespace string, importFuncs, funcs []*wasmFunc, exports []*wasmExport, globals []*wasmGlobal, types []*wasmType, tables [][]uint32) error {
-	const groupSize = 64
-
+func writeInst(out *output, incpath string, namespace string, runtime runtimeRef, numFuncs int, importFuncs, funcs []*wasmFunc, groups []funcGroup, exports []*wasmExport, globals []*wasmGlobal, types []*wasmType, tables [][]uint32) error {
 	var g errgroup.Group
 	g.Go(func() error {
-		f, err := os.Create(filepath.Join(dir, "inst.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
 		m := 0
 		for _, t := range tables {
 			if m < len(t) {
 				m = len(t)
 			}
 		}
-		if err := instHTmpl.Execute(f, struct {
+		if err := out.writeFile("inst.h", instHTmpl, struct {
 			IncludeGuard        string
 			IncludePath         string
 			Namespace           string
@@ -43,6 +25,7 @@ func writeInst(dir string, incpath string, namespace string, importFuncs, funcs
 			Exports             []*wasmExport
 			Funcs               []*wasmFunc
 			Types               []*wasmType
+			Results             []string
 			Globals             []*wasmGlobal
 			NumFuncs            int
 			NumTable            int
@@ -51,12 +34,13 @@ func writeInst(dir string, incpath string, namespace string, importFuncs, funcs
 			IncludeGuard:        includeGuard(namespace) + "_INST_H",
 			IncludePath:         incpath,
 			Namespace:           namespace,
-			ImportFuncs:         importFuncs,
+			ImportFuncs:         uniqueImportFuncs(importFuncs),
 			Exports:             exports,
 			Funcs:               funcs,
 			Types:               types,
+			Results:             resultsStructs(types),
 			Globals:             globals,
-			NumFuncs:            len(importFuncs) + len(funcs),
+			NumFuncs:            numFuncs,
 			NumTable:            len(tables),
 			NumMaxTableElements: m,
 		}); err != nil {
@@ -65,24 +49,19 @@ func writeInst(dir string, incpath string, namespace string, importFuncs, funcs
 		return nil
 	})
 
-	for i := 0; i < (len(funcs)-1)/groupSize+1; i++ {
-		i := i
-		fs := funcs[groupSize*i : min(groupSize*(i+1), len(funcs))]
+	for _, group := range groups {
+		group := group
 		g.Go(func() error {
-			f, err := os.Create(filepath.Join(dir, fmt.Sprintf("inst.funcs%d.cpp", i)))
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			if err := instFuncCppTmpl.Execute(f, struct {
+			if err := out.writeFile(group.Name, instFuncCppTmpl, struct {
 				IncludePath string
 				Namespace   string
+				Runtime     runtimeRef
 				Funcs       []*wasmFunc
 			}{
 				IncludePath: incpath,
 				Namespace:   namespace,
-				Funcs:       fs,
+				Runtime:     runtime,
+				Funcs:       group.Funcs,
 			}); err != nil {
 				return err
 			}
@@ -90,13 +69,7 @@ func writeInst(dir string, incpath string, namespace string, importFuncs, funcs
 		})
 	}
 	g.Go(func() error {
-		f, err := os.Create(filepath.Join(dir, "inst.exports.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := instExportsCppTmpl.Execute(f, struct {
+		if err := out.writeFile("inst.exports.cpp", instExportsCppTmpl, struct {
 			IncludePath string
 			Namespace   string
 			Exports     []*wasmExport
@@ -110,13 +83,7 @@ func writeInst(dir string, incpath string, namespace string, importFuncs, funcs
 		return nil
 	})
 	g.Go(func() error {
-		f, err := os.Create(filepath.Join(dir, "inst.init.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := instInitCppTmpl.Execute(f, struct {
+		if err := out.writeFile("inst.init.cpp", instInitCppTmpl, struct {
 			IncludePath string
 			Namespace   string
 			ImportFuncs []*wasmFunc
@@ -149,11 +116,14 @@ var instHTmpl = template.Must(template.New("inst.h").Parse(`// Code generated by
 #define {{.IncludeGuard}}
 
 #include <cstdint>
+#include <limits>
 
 namespace {{.Namespace}} {
 
 class Mem;
-
+{{range $value := .Results}}
+{{$value}}
+{{end}}
 class IImport {
 public:
   virtual ~IImport();
@@ -175,6 +145,9 @@ private:
 {{range $value := .Types}}    Type{{.Index}} type{{.Index}}_;
 {{end}}  };
 
+  static float FloatFromBits(uint32_t bits);
+  static double DoubleFromBits(uint64_t bits);
+
 {{range $value := .Funcs}}{{$value.CppDecl "  " false false}}
 
 {{end}}  Mem* mem_;
@@ -196,11 +169,15 @@ var instFuncCppTmpl = template.Must(template.New("inst.funcs.cpp").Parse(`// Cod
 
 #include <cassert>
 #include <cmath>
-#include "{{.IncludePath}}bits.h"
+#include <cstdlib>
+#include <iostream>
+#include "{{.Runtime.IncludePath}}bits.h"
 #include "{{.IncludePath}}mem.h"
 
 namespace {{.Namespace}} {
-
+{{if .Runtime.Namespace}}
+using namespace {{.Runtime.Namespace}};
+{{end}}
 {{range $value := .Funcs}}{{$value.CppImpl "Inst" ""}}
 {{end}}}
 `))
@@ -219,9 +196,23 @@ var instInitCppTmpl = template.Must(template.New("inst.init.cpp").Parse(`// Code
 
 #include "{{.IncludePath}}inst.h"
 
+#include <cstring>
+
 namespace {{.Namespace}} {
 
 IImport::~IImport() = default;
+{{range $value := .Globals}}{{$value.CppDefinition}}{{end}}
+float Inst::FloatFromBits(uint32_t bits) {
+  float f;
+  std::memcpy(&f, &bits, sizeof(f));
+  return f;
+}
+
+double Inst::DoubleFromBits(uint64_t bits) {
+  double d;
+  std::memcpy(&d, &bits, sizeof(d));
+  return d;
+}
 
 Inst::Inst(Mem* mem, IImport* import)
     : mem_{mem},
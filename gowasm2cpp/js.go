@@ -3,47 +3,29 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
 )
 
-func writeJS(dir string, incpath string, namespace string) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "js.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := jsHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_JS_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeJS(out *output, incpath string, namespace string) error {
+	if err := out.writeFile("js.h", jsHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+	}{
+		IncludeGuard: includeGuard(namespace) + "_JS_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "js.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := jsCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("js.cpp", jsCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
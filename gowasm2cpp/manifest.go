@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// ManifestFileName is the name of the manifest file generated alongside the C++ files.
+const ManifestFileName = "manifest.json"
+
+// Manifest describes the generated C++ files for build systems.
+//
+// The paths are relative to the output directory.
+type Manifest struct {
+	Sources     []string         `json:"sources"`
+	Headers     []string         `json:"headers"`
+	Namespace   string           `json:"namespace"`
+	IncludePath string           `json:"include_path"`
+	WasmSHA256  string           `json:"wasm_sha256"`
+	GoVersion   string           `json:"go_version,omitempty"`
+	Imports     []ManifestImport `json:"imports"`
+	Exports     []string         `json:"exports"`
+
+	// Mode is "runtime" with Options.RuntimeOnly, "program" with Options.ProgramOnly, or empty when both the runtime
+	// and the program are generated.
+	Mode string `json:"mode,omitempty"`
+
+	// RuntimeNamespace is the namespace of the runtime if it differs from Namespace.
+	RuntimeNamespace string `json:"runtime_namespace,omitempty"`
+
+	// RemovedFuncs is the number of the functions removed as they are unreachable.
+	RemovedFuncs int `json:"removed_funcs"`
+
+	// RemovedCodeSize is the total size of the Wasm code of the removed functions in bytes.
+	RemovedCodeSize int `json:"removed_code_size"`
+}
+
+// ManifestImport represents an import of the Wasm module.
+type ManifestImport struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+}
+
+// addFiles adds the C++ sources and headers in names to the manifest.
+func (m *Manifest) addFiles(names []string) {
+	for _, n := range names {
+		switch {
+		case strings.HasSuffix(n, ".cpp"):
+			m.Sources = append(m.Sources, n)
+		case strings.HasSuffix(n, ".h"):
+			m.Headers = append(m.Headers, n)
+		}
+	}
+}
+
+func writeManifest(out *output, manifest *Manifest) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	return out.write(ManifestFileName, b)
+}
+
+var goVersionRe = regexp.MustCompile(`go1\.[0-9]+(\.[0-9]+|beta[0-9]+|rc[0-9]+)?`)
+
+// goVersion returns the version of Go that compiled the module, or an empty string if the version is unknown.
+func goVersion(mod *wasm.Module) string {
+	// The producers section exists in newer Go.
+	if c := mod.Custom("producers"); c != nil {
+		if v := goVersionFromProducers(c.Data); v != "" {
+			return v
+		}
+	}
+	return goVersionFromData(mod.Data)
+}
+
+// maxBuildVersionLen is the maximum length of runtime.buildVersion that goVersionFromData looks for.
+const maxBuildVersionLen = 64
+
+// goVersionFromData returns the value of runtime.buildVersion in the data segments, or an empty string if the value
+// is not found.
+//
+// runtime.buildVersion is a string variable, so the data segments have its header, a pair of a 64-bit pointer and a
+// 64-bit length aligned to 8 bytes, that points to the version string. A version-like string that no such header
+// points to, e.g., a string literal, is ignored. If two or more headers point to different versions, the version is
+// unknown.
+func goVersionFromData(data []wasm.Data) string {
+	type segment struct {
+		offset uint64
+		data   []byte
+	}
+	var segs []segment
+	for _, e := range data {
+		offset, err := evalI32InitExpr(e.Offset, nil)
+		if err != nil || offset < 0 {
+			continue
+		}
+		segs = append(segs, segment{
+			offset: uint64(offset),
+			data:   e.Data,
+		})
+	}
+	sort.SliceStable(segs, func(i, j int) bool {
+		return segs[i].offset < segs[j].offset
+	})
+
+	// load returns the n bytes at addr, or nil if the bytes are not in one segment.
+	load := func(addr, n uint64) []byte {
+		i := sort.Search(len(segs), func(i int) bool {
+			return segs[i].offset > addr
+		}) - 1
+		if i < 0 {
+			return nil
+		}
+		s := segs[i]
+		if addr-s.offset+n > uint64(len(s.data)) {
+			return nil
+		}
+		return s.data[addr-s.offset:][:n]
+	}
+
+	var version string
+	for _, s := range segs {
+		for i := (8 - s.offset%8) % 8; i+16 <= uint64(len(s.data)); i += 8 {
+			ptr := binary.LittleEndian.Uint64(s.data[i:])
+			n := binary.LittleEndian.Uint64(s.data[i+8:])
+			if n == 0 || n > maxBuildVersionLen || ptr >= 1<<32 {
+				continue
+			}
+			str := load(ptr, n)
+			if !bytes.HasPrefix(str, []byte("go1.")) {
+				continue
+			}
+			// A version can have a suffix like " X:boringcrypto".
+			loc := goVersionRe.FindIndex(str)
+			if loc == nil || loc[0] != 0 || (loc[1] < len(str) && str[loc[1]] != ' ') {
+				continue
+			}
+			v := string(str[:loc[1]])
+			if version != "" && version != v {
+				return ""
+			}
+			version = v
+		}
+	}
+	return version
+}
+
+// goVersionFromProducers parses the producers section and returns the version of the Go language.
+//
+// See https://github.com/WebAssembly/tool-conventions/blob/master/ProducersSection.md
+func goVersionFromProducers(data []byte) string {
+	r := bytes.NewReader(data)
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", err
+		}
+		if n > uint64(r.Len()) {
+			return "", io.ErrUnexpectedEOF
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	fieldNum, err := binary.ReadUvarint(r)
+	if err != nil {
+		return ""
+	}
+	for i := uint64(0); i < fieldNum; i++ {
+		field, err := readString()
+		if err != nil {
+			return ""
+		}
+		valueNum, err := binary.ReadUvarint(r)
+		if err != nil {
+			return ""
+		}
+		for j := uint64(0); j < valueNum; j++ {
+			name, err := readString()
+			if err != nil {
+				return ""
+			}
+			version, err := readString()
+			if err != nil {
+				return ""
+			}
+			if field == "language" && name == "Go" {
+				return goVersionRe.FindString(version)
+			}
+		}
+	}
+	return ""
+}
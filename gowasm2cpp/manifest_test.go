@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// stringVarData returns a data segment at offset, which must be aligned to 8 bytes and less than 64. The segment has
+// prefix, and then a string variable of value, i.e., the header of a string and the bytes of value.
+func stringVarData(offset int, prefix string, value string) wasm.Data {
+	b := []byte(prefix)
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+	var header [16]byte
+	binary.LittleEndian.PutUint64(header[:8], uint64(offset+len(b)+len(header)))
+	binary.LittleEndian.PutUint64(header[8:], uint64(len(value)))
+	b = append(b, header[:]...)
+	b = append(b, value...)
+	return wasm.Data{
+		Offset: []byte{0x41, byte(offset), 0x0b},
+		Data:   b,
+	}
+}
+
+// producers returns a producers section that has the field language with the value Go of version.
+func producers(version string) []byte {
+	var b []byte
+	str := func(s string) {
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+	b = append(b, 0x01)
+	str("language")
+	b = append(b, 0x01)
+	str("Go")
+	str(version)
+	return b
+}
+
+func TestGoVersion(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Module *wasm.Module
+		Want   string
+	}{
+		{
+			Name: "producers",
+			Module: &wasm.Module{
+				Customs: []wasm.CustomSection{{Name: "producers", Data: producers("go1.21.3")}},
+				Data:    []wasm.Data{stringVarData(0, "", "go1.20")},
+			},
+			Want: "go1.21.3",
+		},
+		{
+			Name: "broken producers",
+			Module: &wasm.Module{
+				// The length of the field name is 2^63-1.
+				Customs: []wasm.CustomSection{{Name: "producers", Data: []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}}},
+				Data:    []wasm.Data{stringVarData(0, "", "go1.20")},
+			},
+			Want: "go1.20",
+		},
+		{
+			Name:   "build version",
+			Module: &wasm.Module{Data: []wasm.Data{stringVarData(0, "", "go1.16.15")}},
+			Want:   "go1.16.15",
+		},
+		{
+			Name:   "build version with a suffix",
+			Module: &wasm.Module{Data: []wasm.Data{stringVarData(0, "", "go1.20.3 X:boringcrypto")}},
+			Want:   "go1.20.3",
+		},
+		{
+			Name:   "string literal before build version",
+			Module: &wasm.Module{Data: []wasm.Data{stringVarData(0, "go1.9", "go1.17.2")}},
+			Want:   "go1.17.2",
+		},
+		{
+			Name: "build version in another segment",
+			Module: &wasm.Module{Data: []wasm.Data{
+				{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("go1.9 go1.10")},
+				stringVarData(32, "", "go1.18"),
+			}},
+			Want: "go1.18",
+		},
+		{
+			Name:   "string literal only",
+			Module: &wasm.Module{Data: []wasm.Data{{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("\x00go1.20\x00")}}},
+			Want:   "",
+		},
+		{
+			Name:   "not a version",
+			Module: &wasm.Module{Data: []wasm.Data{stringVarData(0, "", "go1.x")}},
+			Want:   "",
+		},
+		{
+			Name: "ambiguous string variables",
+			Module: &wasm.Module{Data: []wasm.Data{
+				stringVarData(0, "", "go1.16"),
+				stringVarData(32, "", "go1.20"),
+			}},
+			Want: "",
+		},
+	}
+	for _, tc := range testCases {
+		if got := goVersion(tc.Module); got != tc.Want {
+			t.Errorf("%s: got: %q, want: %q", tc.Name, got, tc.Want)
+		}
+	}
+}
+
+func TestManifestAddFiles(t *testing.T) {
+	var m Manifest
+	m.addFiles([]string{"go.h", "go.cpp", "CMakeLists.txt", "inst.funcs0.cpp"})
+	if got, want := m.Sources, []string{"go.cpp", "inst.funcs0.cpp"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Sources: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Headers, []string{"go.h"}; len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("Headers: got: %v, want: %v", got, want)
+	}
+}
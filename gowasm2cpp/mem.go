@@ -3,9 +3,18 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+const (
+	pageSize = 64 * 1024
+
+	// maxMemory is the maximum size of the linear memory in Wasm.
+	maxMemory = 4 * 1024 * 1024 * 1024
+
+	defaultReservedMemory = 1 * 1024 * 1024 * 1024
 )
 
 type wasmData struct {
@@ -13,46 +22,50 @@ type wasmData struct {
 	Data   []byte
 }
 
-func writeMem(dir string, incpath string, namespace string, initPageNum int, data []wasmData) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "mem.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := memHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_MEM_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeMem(out *output, incpath string, namespace string, runtime runtimeRef, limits wasm.Limits, reservedMemory, maxMem int64, data []wasmData) error {
+	if maxMem == 0 {
+		maxMem = maxMemory
+	}
+	// The maximum memory size of the module is also respected.
+	if limits.HasMaximum && int64(limits.Maximum)*pageSize < maxMem {
+		maxMem = int64(limits.Maximum) * pageSize
+	}
+	if reservedMemory == 0 {
+		reservedMemory = defaultReservedMemory
+	}
+	if reservedMemory > maxMem {
+		reservedMemory = maxMem
+	}
+
+	if err := out.writeFile("mem.h", memHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+		Runtime      runtimeRef
+	}{
+		IncludeGuard: includeGuard(namespace) + "_MEM_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+		Runtime:      runtime,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "mem.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := memCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-			InitPageNum int
-			Data        []wasmData
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-			InitPageNum: initPageNum,
-			Data:        data,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("mem.cpp", memCppTmpl, struct {
+		IncludePath    string
+		Namespace      string
+		InitPageNum    int
+		ReservedMemory int64
+		MaxMemory      int64
+		Data           []wasmData
+	}{
+		IncludePath:    incpath,
+		Namespace:      namespace,
+		InitPageNum:    int(limits.Initial),
+		ReservedMemory: reservedMemory,
+		MaxMemory:      maxMem,
+		Data:           data,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
@@ -65,10 +78,12 @@ var memHTmpl = template.Must(template.New("mem.h").Parse(`// Code generated by g
 #include <cstdint>
 #include <string>
 #include <vector>
-#include "{{.IncludePath}}bytes.h"
+#include "{{.Runtime.IncludePath}}bytes.h"
 
 namespace {{.Namespace}} {
-
+{{if .Runtime.Namespace}}
+using namespace {{.Runtime.Namespace}};
+{{end}}
 class Mem {
 public:
   static constexpr int32_t kPageSize = 64 * 1024;
@@ -147,6 +162,10 @@ public:
   int Memcmp(int32_t a, int32_t b, int32_t len);
   int32_t Memchr(int32_t ptr, int32_t ch, int32_t count);
 
+  // Memmove and Memset implement memory.copy and memory.fill. len is treated as unsigned.
+  void Memmove(int32_t dst, int32_t src, int32_t len);
+  void Memset(int32_t dst, int32_t val, int32_t len);
+
 private:
   Mem(const Mem&) = delete;
   Mem& operator=(const Mem&) = delete;
@@ -179,7 +198,7 @@ namespace {
 
 Mem::Mem() {
   // Reserving 4GB memory might fail on some consoles. 1GB should be safe in most environments.
-  bytes_.reserve(1ul * 1024 * 1024 * 1024);
+  bytes_.reserve({{.ReservedMemory}}ull);
   bytes_.resize({{.InitPageNum}} * kPageSize);
   bytes_begin_ = &*bytes_.begin();
 {{range $index, $value := .Data}}  std::memcpy(bytes_begin_ + {{$value.Offset}}, data_segment_data{{$index}}, {{len $value.Data}});
@@ -191,16 +210,19 @@ int32_t Mem::GetSize() const {
 }
 
 int32_t Mem::Grow(int32_t delta) {
-  constexpr size_t kMaxMemorySizeOnWasm = 4ul * 1024ul * 1024ul * 1024ul;
+  constexpr size_t kMaxMemorySize = {{.MaxMemory}}ull;
 
   int prev_size = GetSize();
-  size_t new_size = (prev_size + delta) * kPageSize;
+  size_t new_size = (static_cast<size_t>(prev_size) + delta) * kPageSize;
+  if (new_size > kMaxMemorySize) {
+    return -1;
+  }
   if (bytes_.capacity() < new_size) {
     size_t new_capacity = bytes_.capacity();
     while (new_capacity < new_size) {
       new_capacity *= 2;
     }
-    new_capacity = std::min(new_capacity, kMaxMemorySizeOnWasm);
+    new_capacity = std::min(new_capacity, kMaxMemorySize);
     bytes_.reserve(new_capacity);
     bytes_begin_ = &*bytes_.begin();
   }
@@ -240,5 +262,13 @@ int32_t Mem::Memchr(int32_t ptr, int32_t ch, int32_t count) {
   return static_cast<int32_t>(reinterpret_cast<uint8_t*>(result) - bytes_begin_);
 }
 
+void Mem::Memmove(int32_t dst, int32_t src, int32_t len) {
+  std::memmove(bytes_begin_ + dst, bytes_begin_ + src, static_cast<uint32_t>(len));
+}
+
+void Mem::Memset(int32_t dst, int32_t val, int32_t len) {
+  std::memset(bytes_begin_ + dst, static_cast<uint8_t>(val), static_cast<uint32_t>(len));
+}
+
 }
 `))
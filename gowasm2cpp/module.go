@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// module is a decoded Wasm module with the information to generate C++ files.
+type module struct {
+	mod         *wasm.Module
+	types       []*wasmType
+	globals     []*wasmGlobal
+	importFuncs []*wasmFunc
+	funcs       []*wasmFunc
+	exports     []*wasmExport
+	tables      [][]uint32
+	data        []wasmData
+
+	// hostFuncs is the imported functions implemented by the Host class.
+	hostFuncs []*hostFunc
+
+	// wasi indicates whether the module is built for WASI (GOOS=wasip1).
+	wasi bool
+}
+
+// newModule decodes the Wasm binary.
+// registry has the C++ function bodies for imported functions and overridden functions.
+// overrides is the overrides that are already registered in registry. newModule checks that the functions exist.
+// The runtime and syscall/js imports without such bodies use the built-in bodies for the detected Go version.
+func newModule(bin []byte, registry *Registry, overrides map[string]Override) (*module, error) {
+	mod, err := wasm.DecodeModule(bin)
+	if err != nil {
+		return nil, err
+	}
+	if len(mod.Memories) == 0 {
+		return nil, fmt.Errorf("gowasm2cpp: the module must have a memory")
+	}
+
+	var types []*wasmType
+	for i := range mod.Types {
+		types = append(types, &wasmType{
+			Sig:   &mod.Types[i],
+			Index: i,
+		})
+	}
+
+	var globals []*wasmGlobal
+	for i, e := range mod.Globals {
+		t, v, err := evalInitExpr(e.Init, globals)
+		if err != nil {
+			return nil, err
+		}
+		if t != e.Type.Type {
+			return nil, fmt.Errorf("gowasm2cpp: type mismatch in the initializer of global %d: %s vs %s", i, valueTypeNames[e.Type.Type], valueTypeNames[t])
+		}
+		globals = append(globals, &wasmGlobal{
+			Type:    e.Type.Type,
+			Index:   i,
+			Mutable: e.Type.Mutable,
+			Init:    v,
+		})
+	}
+
+	abi, err := selectJSABI(mod)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := map[string]struct{}{}
+	var ifs []*wasmFunc
+	var hfs []*hostFunc
+	// imported maps the names to the first imports. A module can import the same function more than once.
+	imported := map[string]*wasmFunc{}
+	var wasi bool
+	for i, e := range mod.Imports {
+		if e.Kind != wasm.ExternalFunction {
+			return nil, fmt.Errorf("gowasm2cpp: import type %d is not implemented", e.Kind)
+		}
+		if e.ModuleName == wasiModule {
+			wasi = true
+		}
+		name := e.FieldName
+		if !isGoImportModule(e.ModuleName) {
+			name = e.ModuleName + "." + e.FieldName
+		}
+		sig := types[e.TypeIndex].Sig
+		f0, dup := imported[name]
+		if dup {
+			if s0, s1 := formatSignature(f0.Wasm.Sig.ParamTypes, f0.Wasm.Sig.ReturnTypes), formatSignature(sig.ParamTypes, sig.ReturnTypes); s0 != s1 {
+				return nil, fmt.Errorf("gowasm2cpp: %s is imported with different signatures: %s and %s", name, s0, s1)
+			}
+		}
+		bodyStr, ok, err := registry.body(name, sig)
+		if err != nil {
+			return nil, err
+		}
+		if !ok && abi != nil && isGoImportModule(e.ModuleName) {
+			bodyStr, ok = abi.bodies[name]
+		}
+		if _, ok := overrides[name]; ok {
+			overridden[name] = struct{}{}
+		}
+		if !ok && !isGoImportModule(e.ModuleName) {
+			if dup {
+				bodyStr = f0.BodyStr
+			} else {
+				h := newHostFunc(e.ModuleName, e.FieldName, sig)
+				hfs = append(hfs, h)
+				bodyStr = h.bodyStr()
+			}
+		}
+		f := &wasmFunc{
+			Type: types[e.TypeIndex],
+			Wasm: wasm.Function{
+				Sig:  sig,
+				Name: name,
+			},
+			Globals: globals,
+			Index:   i,
+			Import:  true,
+			BodyStr: bodyStr,
+		}
+		if !dup {
+			imported[name] = f
+		}
+		ifs = append(ifs, f)
+	}
+
+	names, err := mod.FunctionNames()
+	if err != nil {
+		return nil, err
+	}
+	var fs []*wasmFunc
+	for i, t := range mod.Functions {
+		name := names[uint32(i+len(mod.Imports))]
+		bodyStr, ok, err := registry.body(name, types[t].Sig)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := overrides[name]; ok {
+			overridden[name] = struct{}{}
+		}
+		var body *wasm.FunctionBody
+		if !ok {
+			body = &mod.Code[i]
+		}
+		fs = append(fs, &wasmFunc{
+			Type: types[t],
+			Wasm: wasm.Function{
+				Sig:  types[t].Sig,
+				Body: body,
+				Name: name,
+			},
+			Globals: globals,
+			Index:   i + len(mod.Imports),
+			BodyStr: bodyStr,
+		})
+	}
+	for name := range overrides {
+		if _, ok := overridden[name]; !ok {
+			return nil, fmt.Errorf("gowasm2cpp: function to override is not found: %q", name)
+		}
+	}
+
+	var exports []*wasmExport
+	for _, e := range mod.Exports {
+		switch e.Kind {
+		case wasm.ExternalFunction:
+			exports = append(exports, &wasmExport{
+				Index: int(e.Index),
+				Name:  e.FieldStr,
+			})
+		case wasm.ExternalMemory:
+			// Ignore
+		default:
+			return nil, fmt.Errorf("export type %d is not implemented", e.Kind)
+		}
+	}
+
+	allfs := append(ifs, fs...)
+	for _, e := range exports {
+		e.Funcs = allfs
+	}
+	for _, f := range ifs {
+		f.Funcs = allfs
+		f.Types = types
+	}
+	for _, f := range fs {
+		f.Funcs = allfs
+		f.Types = types
+	}
+
+	if mod.Start != nil {
+		return nil, fmt.Errorf("start section must be nil but not")
+	}
+	if wasi {
+		var start bool
+		for _, e := range exports {
+			if e.Name == wasiStart {
+				start = true
+				break
+			}
+		}
+		if !start {
+			return nil, fmt.Errorf("gowasm2cpp: the WASI module must export %s", wasiStart)
+		}
+	}
+
+	tables := make([][]uint32, len(mod.Tables))
+	for i, e := range mod.Elements {
+		if int(e.Index) >= len(tables) {
+			return nil, fmt.Errorf("gowasm2cpp: element segment %d refers to table %d but the module has %d tables", i, e.Index, len(tables))
+		}
+		offset, err := evalI32InitExpr(e.Offset, globals)
+		if err != nil {
+			return nil, err
+		}
+		// The segment must fit in the initial table as the table is not grown before the segments are applied.
+		if size := int64(mod.Tables[e.Index].Limits.Initial); offset < 0 || int64(offset)+int64(len(e.Elems)) > size {
+			return nil, fmt.Errorf("gowasm2cpp: element segment %d at offset %d with %d elements is out of the table of size %d", i, offset, len(e.Elems), size)
+		}
+		if diff := int(offset) + int(len(e.Elems)) - int(len(tables[e.Index])); diff > 0 {
+			tables[e.Index] = append(tables[e.Index], make([]uint32, diff)...)
+		}
+		copy(tables[e.Index][offset:], e.Elems)
+	}
+
+	var data []wasmData
+	for i, e := range mod.Data {
+		offset, err := evalI32InitExpr(e.Offset, globals)
+		if err != nil {
+			return nil, err
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("gowasm2cpp: data segment %d has a negative offset %d", i, offset)
+		}
+		data = append(data, wasmData{
+			Offset: int(offset),
+			Data:   e.Data,
+		})
+	}
+
+	return &module{
+		mod:         mod,
+		types:       types,
+		globals:     globals,
+		importFuncs: ifs,
+		funcs:       fs,
+		exports:     exports,
+		tables:      tables,
+		data:        data,
+		hostFuncs:   hfs,
+		wasi:        wasi,
+	}, nil
+}
+
+// numFuncs returns the number of all the functions including the imported functions and the removed functions.
+func (m *module) numFuncs() int {
+	return len(m.mod.Imports) + len(m.mod.Functions)
+}
+
+// uniqueImportFuncs returns the imported functions without the second and later imports of the same functions.
+// Such imports share one C++ function.
+func uniqueImportFuncs(importFuncs []*wasmFunc) []*wasmFunc {
+	var fs []*wasmFunc
+	seen := map[string]struct{}{}
+	for _, f := range importFuncs {
+		if _, ok := seen[f.Wasm.Name]; ok {
+			continue
+		}
+		seen[f.Wasm.Name] = struct{}{}
+		fs = append(fs, f)
+	}
+	return fs
+}
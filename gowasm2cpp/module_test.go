@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// elemModule returns a Wasm module that has one empty function exported as run, one page of memory, the given table
+// section, and one element segment that puts the function at the i32 offset encoded as offset.
+func elemModule(table []byte, offset ...byte) []byte {
+	ss := [][]byte{
+		Section(SectionType, Vec(FuncType(nil, nil))...),
+		Section(SectionFunction, Vec(U32(0))...),
+	}
+	if table != nil {
+		ss = append(ss, table)
+	}
+	elem := append([]byte{0x00, 0x41}, offset...)
+	elem = append(elem, 0x0b)
+	elem = append(elem, Vec(U32(0))...)
+	ss = append(ss,
+		Section(SectionMemory, Vec(Limits(1))...),
+		Section(SectionExport, Vec(Export("run", KindFunction, 0))...),
+		Section(SectionElement, Vec(elem)...),
+		Section(SectionCode, Vec(Body())...),
+	)
+	return Module(ss...)
+}
+
+// table2 is a table section of one funcref table with two elements.
+var table2 = Section(SectionTable, Vec(Table(2))...)
+
+func TestNewModuleTable(t *testing.T) {
+	m, err := newModule(elemModule(table2, 0x01), DefaultRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.tables), 1; got != want {
+		t.Fatalf("len(m.tables): got: %d, want: %d", got, want)
+	}
+	if got, want := m.tables[0], []uint32{0, 0}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("m.tables[0]: got: %v, want: %v", got, want)
+	}
+}
+
+func TestNewModuleInvalidElements(t *testing.T) {
+	cases := []struct {
+		Name string
+		Bin  []byte
+		Err  string
+	}{
+		{
+			Name: "no table",
+			Bin:  elemModule(nil, 0x00),
+			Err:  "refers to table 0 but the module has 0 tables",
+		},
+		{
+			Name: "negative offset",
+			Bin:  elemModule(table2, 0x7f),
+			Err:  "at offset -1",
+		},
+		{
+			Name: "overflowing offset",
+			Bin:  elemModule(table2, 0x02),
+			Err:  "out of the table of size 2",
+		},
+		{
+			Name: "large offset",
+			Bin:  elemModule(table2, 0xff, 0xff, 0xff, 0xff, 0x07),
+			Err:  "at offset 2147483647",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			_, err := newModule(c.Bin, DefaultRegistry(), nil)
+			if err == nil {
+				t.Fatal("newModule must return an error")
+			}
+			if !strings.Contains(err.Error(), c.Err) {
+				t.Errorf("error: got: %v, want: containing %q", err, c.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// Multiple return values are lowered to a struct with a member for each value.
+// The struct is named after the value types, e.g., ResultsI32I64 has int32_t v0 and int64_t v1.
+
+// resultsCpp returns the C++ return type for the Wasm result types ts.
+func resultsCpp(ts []wasm.ValueType) string {
+	switch len(ts) {
+	case 0:
+		return returnTypeVoid.Cpp()
+	case 1:
+		return wasmTypeToReturnType(ts[0]).Cpp()
+	default:
+		return resultsStructName(ts)
+	}
+}
+
+func resultsStructName(ts []wasm.ValueType) string {
+	var b strings.Builder
+	b.WriteString("Results")
+	for _, t := range ts {
+		b.WriteString(strings.ToUpper(valueTypeNames[t]))
+	}
+	return b.String()
+}
+
+// resultsStruct returns the C++ definition of the struct for the result types ts.
+func resultsStruct(ts []wasm.ValueType) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "struct %s {\n", resultsStructName(ts))
+	for i, t := range ts {
+		fmt.Fprintf(&b, "  %s v%d;\n", wasmTypeToReturnType(t).Cpp(), i)
+	}
+	b.WriteString("};")
+	return b.String()
+}
+
+// resultsStructs returns the C++ definitions of the structs for the function types with multiple return values.
+func resultsStructs(types []*wasmType) []string {
+	defs := map[string]string{}
+	for _, t := range types {
+		if ts := t.Sig.ReturnTypes; len(ts) > 1 {
+			defs[resultsStructName(ts)] = resultsStruct(ts)
+		}
+	}
+	var names []string
+	for n := range defs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var r []string
+	for _, n := range names {
+		r = append(r, defs[n])
+	}
+	return r
+}
+
+// resultsExpr returns the C++ expression to make the struct for the result types ts from exprs.
+func resultsExpr(ts []wasm.ValueType, exprs []string) string {
+	args := make([]string, len(exprs))
+	for i, e := range exprs {
+		args[i] = fmt.Sprintf("static_cast<%s>(%s)", wasmTypeToReturnType(ts[i]).Cpp(), e)
+	}
+	return fmt.Sprintf("%s{%s}", resultsStructName(ts), strings.Join(args, ", "))
+}
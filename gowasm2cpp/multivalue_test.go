@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// multiValueModule is a Wasm module that imports env.pair of (i32) -> (i32, i64), and exports main.swap of
+// (i32, i32) -> (i32, i32) and main.block that uses a block of (i32, i32) -> (i32, i32).
+var multiValueModule = Module(
+	Section(SectionType, Vec(
+		FuncType([]byte{I32, I32}, []byte{I32, I32}),
+		FuncType([]byte{I32}, []byte{I32, I64}),
+		FuncType(nil, []byte{I32}),
+	)...),
+	Section(SectionImport, Vec(ImportFunc("env", "pair", 1))...),
+	Section(SectionFunction, Vec(U32(0), U32(2))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+	Section(SectionExport, Vec(
+		Export("swap", KindFunction, 1),
+		Export("block", KindFunction, 2),
+		Export("mem", KindMemory, 0),
+	)...),
+	Section(SectionCode, Vec(
+		// local.get 1; local.get 0
+		Body(0x20, 0x01, 0x20, 0x00),
+		// i32.const 10; i32.const 3; block (type 0) i32.sub; i32.const 100; end; i32.mul
+		Body(0x41, 0x0a, 0x41, 0x03, 0x02, 0x00, 0x6b, 0x41, 0xe4, 0x00, 0x0b, 0x6c),
+	)...),
+	Names("pair", "main.swap", "main.block"),
+)
+
+func TestResultsCpp(t *testing.T) {
+	testCases := []struct {
+		Types []wasm.ValueType
+		Want  string
+	}{
+		{nil, "void"},
+		{[]wasm.ValueType{wasm.ValueTypeF32}, "float"},
+		{[]wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI64}, "ResultsI32I64"},
+		{[]wasm.ValueType{wasm.ValueTypeF64, wasm.ValueTypeF64, wasm.ValueTypeI32}, "ResultsF64F64I32"},
+	}
+	for _, tc := range testCases {
+		if got := resultsCpp(tc.Types); got != tc.Want {
+			t.Errorf("resultsCpp(%v): got: %q, want: %q", tc.Types, got, tc.Want)
+		}
+	}
+}
+
+func TestGenerateMultiValue(t *testing.T) {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    multiValueModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		File string
+		Want string
+	}{
+		{"inst.h", "struct ResultsI32I32 {\n  int32_t v0;\n  int32_t v1;\n};"},
+		{"inst.h", "ResultsI32I32 swap(int32_t arg0, int32_t arg1);"},
+		{"inst.h", "using Type1 = ResultsI32I64 (Inst::*)(int32_t arg0);"},
+		{"inst.funcs0.cpp", "return ResultsI32I32{static_cast<int32_t>(local1_), static_cast<int32_t>(local0_)};"},
+		{"inst.funcs0.cpp", "return (stack1_2_) * (stack1_3_);"},
+		{"host.h", "virtual void env_pair(int32_t arg0, int32_t* ret0, int64_t* ret1) = 0;"},
+		{"go.cpp", "go_->host_->env_pair(local0_, &r.v0, &r.v1);"},
+	}
+	for _, tc := range testCases {
+		if got := out[tc.File]; !bytes.Contains(got, []byte(tc.Want)) {
+			t.Errorf("%s doesn't have %q:\n%s", tc.File, tc.Want, got)
+		}
+	}
+}
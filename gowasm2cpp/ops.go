@@ -5,18 +5,13 @@ package gowasm2cpp
 import (
 	"fmt"
 	"math"
-	"os"
 	"regexp"
-	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/go-interpreter/wagon/disasm"
-	"github.com/go-interpreter/wagon/wasm"
-	"github.com/go-interpreter/wagon/wasm/operators"
-
 	"github.com/hajimehoshi/go2cpp/internal/stackvar"
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
 )
 
 type returnType int
@@ -61,6 +56,22 @@ func (r returnType) stackVarType() stackvar.Type {
 	}
 }
 
+// bodyError is an error of a malformed function body, e.g., an out-of-range index or a stack underflow.
+//
+// The conversion panics with a bodyError, and bodyToCpp recovers it as an error of the function. Any other panic
+// is a bug of the converter and is not recovered.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return e.err.Error()
+}
+
+func panicBodyError(format string, args ...interface{}) {
+	panic(&bodyError{err: fmt.Errorf(format, args...)})
+}
+
 type indexStack struct {
 	newIdx int
 	stack  []int
@@ -103,9 +114,17 @@ const (
 )
 
 type block struct {
-	typ       blockType
-	ret       string
-	stackvars *stackvar.StackVars
+	typ blockType
+
+	// params is the variables of the block parameters.
+	params []string
+
+	// rets is the variables of the block results.
+	rets []string
+
+	paramTypes []stackvar.Type
+	hasElse    bool
+	stackvars  *stackvar.StackVars
 }
 
 type blockStack struct {
@@ -137,35 +156,51 @@ func (b *blockStack) varName(idx int) string {
 	return fmt.Sprintf("stack%d_%d_", b.blockIndex(), idx)
 }
 
-func (b *blockStack) PushBlock(btype blockType, ret string) int {
-	b.blocks = append(b.blocks, &block{
-		typ: btype,
-		ret: ret,
+// PushBlock pushes a new block. The parameter variables are pushed to the new block's stack.
+func (b *blockStack) PushBlock(btype blockType, params []string, paramTypes []stackvar.Type, rets []string) int {
+	bl := &block{
+		typ:        btype,
+		params:     params,
+		rets:       rets,
+		paramTypes: paramTypes,
 		stackvars: &stackvar.StackVars{
 			VarName: b.varName,
 		},
-	})
-	return b.indexstack.Push()
+	}
+	b.blocks = append(b.blocks, bl)
+	idx := b.indexstack.Push()
+	bl.pushParams()
+	return idx
 }
 
-func (b *blockStack) PopBlock() (id int, typ blockType, ret string) {
-	bl := b.blocks[len(b.blocks)-1]
+func (bl *block) pushParams() {
+	for i, p := range bl.params {
+		bl.stackvars.Push(p, bl.paramTypes[i])
+	}
+}
+
+func (b *blockStack) PopBlock() (id int, bl *block) {
+	if b.indexstack.Len() == 0 {
+		panicBodyError("unbalanced block")
+	}
+	bl = b.blocks[len(b.blocks)-1]
 	b.blocks = b.blocks[:len(b.blocks)-1]
-	return b.indexstack.Pop(), bl.typ, bl.ret
+	return b.indexstack.Pop(), bl
 }
 
-func (b *blockStack) PeepBlock() (id int, typ blockType, ret string) {
-	bl := b.blocks[len(b.blocks)-1]
-	return b.indexstack.Peep(), bl.typ, bl.ret
+func (b *blockStack) PeepBlock() (id int, bl *block) {
+	if b.indexstack.Len() == 0 {
+		panicBodyError("unbalanced block")
+	}
+	return b.indexstack.Peep(), b.blocks[len(b.blocks)-1]
 }
 
-func (b *blockStack) PeepBlockLevel(level int) (id int, typ blockType, ret bool) {
+func (b *blockStack) PeepBlockLevel(level int) (id int, bl *block, ok bool) {
 	l, ok := b.indexstack.PeepLevel(level)
-	var t blockType
 	if ok {
-		t = b.blocks[len(b.blocks)-1-level].typ
+		bl = b.blocks[len(b.blocks)-1-level]
 	}
-	return l, t, ok
+	return l, bl, ok
 }
 
 func (b *blockStack) Len() int {
@@ -206,13 +241,21 @@ func (b *blockStack) PushExpr(expr string, t stackvar.Type) {
 }
 
 func (b *blockStack) PopExpr() (string, stackvar.Type) {
+	b.checkUnderflow()
 	return b.blocks[len(b.blocks)-1].stackvars.Pop()
 }
 
 func (b *blockStack) PeepExpr() ([]string, string) {
+	b.checkUnderflow()
 	return b.blocks[len(b.blocks)-1].stackvars.Peep()
 }
 
+func (b *blockStack) checkUnderflow() {
+	if len(b.blocks) == 0 || b.blocks[len(b.blocks)-1].stackvars.Len() == 0 {
+		panicBodyError("stack underflow")
+	}
+}
+
 func (b *blockStack) FlushExprsIfNeeded(keyword string) []string {
 	if len(b.blocks) == 0 {
 		return nil
@@ -255,6 +298,14 @@ func (b *blockStack) FlushExprsIfNeeded(keyword string) []string {
 	return stmts
 }
 
+// StackLen returns the number of the values on the current block's stack.
+func (b *blockStack) StackLen() int {
+	if len(b.blocks) == 0 {
+		return 0
+	}
+	return b.blocks[len(b.blocks)-1].stackvars.Len()
+}
+
 func (b *blockStack) IsStackVarEmpty() bool {
 	if len(b.blocks) == 0 {
 		return true
@@ -268,24 +319,103 @@ func (f *wasmFunc) localVariableType(idx int) returnType {
 	}
 
 	idx -= len(f.Wasm.Sig.ParamTypes)
-	var wt wasm.ValueType
 	for _, e := range f.Wasm.Body.Locals {
 		if idx >= int(e.Count) {
 			idx -= int(e.Count)
 			continue
 		}
-		wt = e.Type
-		break
+		return wasmTypeToReturnType(e.Type)
+	}
+	panicBodyError("invalid local index: %d", idx+len(f.Wasm.Sig.ParamTypes))
+	return 0
+}
+
+// disassemble disassembles the function body and removes the unreachable instructions.
+func (f *wasmFunc) disassemble() ([]wasm.Instr, error) {
+	instrs, err := wasm.Disassemble(f.Wasm.Body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	type frame struct {
+		// startUnreachable reports whether the block itself is unreachable.
+		startUnreachable bool
+
+		// unreachable reports whether the current instruction in the block is unreachable.
+		unreachable bool
+	}
+	frames := []*frame{{}}
+
+	var code []wasm.Instr
+	for _, instr := range instrs {
+		top := frames[len(frames)-1]
+		switch instr.Op {
+		case wasm.OpBlock, wasm.OpLoop, wasm.OpIf:
+			frames = append(frames, &frame{
+				startUnreachable: top.unreachable,
+				unreachable:      top.unreachable,
+			})
+			if top.unreachable {
+				continue
+			}
+		case wasm.OpElse:
+			top.unreachable = top.startUnreachable
+			if top.startUnreachable {
+				continue
+			}
+		case wasm.OpEnd:
+			if len(frames) == 1 {
+				return nil, fmt.Errorf("unexpected end")
+			}
+			frames = frames[:len(frames)-1]
+			if top.startUnreachable {
+				continue
+			}
+		default:
+			if top.unreachable {
+				continue
+			}
+			switch instr.Op {
+			case wasm.OpUnreachable, wasm.OpBr, wasm.OpBrTable, wasm.OpReturn:
+				top.unreachable = true
+			}
+		}
+		code = append(code, instr)
 	}
-	return wasmTypeToReturnType(wt)
+	return code, nil
 }
 
-func (f *wasmFunc) bodyToCpp() ([]string, error) {
+// blockSignature returns the parameter and result types of the block type t.
+//
+// t is a signed LEB128 value: A negative value is the empty type or a value type, and a non-negative value is a
+// type index.
+func (f *wasmFunc) blockSignature(t int64) ([]wasm.ValueType, []wasm.ValueType, error) {
+	if t < 0 {
+		switch v := wasm.ValueType(t & 0x7f); v {
+		case wasm.ValueType(wasm.BlockTypeEmpty):
+			return nil, nil, nil
+		case wasm.ValueTypeI32, wasm.ValueTypeI64, wasm.ValueTypeF32, wasm.ValueTypeF64:
+			return nil, []wasm.ValueType{v}, nil
+		}
+		return nil, nil, fmt.Errorf("invalid block type: %d", t)
+	}
+	if t >= int64(len(f.Types)) {
+		return nil, nil, fmt.Errorf("invalid block type: %d", t)
+	}
+	sig := f.Types[t].Sig
+	return sig.ParamTypes, sig.ReturnTypes, nil
+}
+
+func (f *wasmFunc) bodyToCpp() (lines []string, err error) {
+	// A malformed function body causes a panic with a bodyError. Treat it as an error of this function.
 	defer func() {
-		if err := recover(); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			debug.PrintStack()
-			panic(err)
+		if r := recover(); r != nil {
+			berr, ok := r.(*bodyError)
+			if !ok {
+				panic(r)
+			}
+			lines = nil
+			err = berr
 		}
 	}()
 
@@ -293,7 +423,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 	funcs := f.Funcs
 	types := f.Types
 
-	dis, err := disasm.NewDisassembly(f.Wasm, f.Mod)
+	code, err := f.disassemble()
 	if err != nil {
 		return nil, err
 	}
@@ -302,6 +432,9 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 	blockStack := &blockStack{}
 	var tmpidx int
 
+	// Some stack variables must not be merged when they are used across multiple blocks.
+	nomerge := map[string]struct{}{}
+
 	appendBody := func(str string, args ...interface{}) {
 		if len(args) > 0 {
 			str = fmt.Sprintf(str, args...)
@@ -314,124 +447,259 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 		body = append(body, indent+str)
 	}
 
-	gotoOrReturn := func(level int) string {
-		if l, _, ok := blockStack.PeepBlockLevel(level); ok {
-			return fmt.Sprintf("goto label%d;", l)
+	// branchValues pops the n values that a branch carries from the stack.
+	// If materialize is true, the values that are not variables are moved to new variables so that they are
+	// evaluated before the branch, e.g., when the values are used in multiple cases of br_table.
+	branchValues := func(n int, materialize bool) ([]string, []stackvar.Type) {
+		vs := make([]string, n)
+		ts := make([]stackvar.Type, n)
+		for i := n - 1; i >= 0; i-- {
+			vs[i], ts[i] = blockStack.PopExpr()
 		}
-		switch len(sig.ReturnTypes) {
-		case 0:
-			return "return;"
-		default:
-			ls, v := blockStack.PeepExpr()
-			for _, l := range ls {
-				appendBody(l)
+		if !materialize {
+			return vs, ts
+		}
+		for i, v := range vs {
+			if stackVarRe.FindString(v) == v {
+				continue
 			}
-			return fmt.Sprintf("return %s;", v)
+			lhs := blockStack.PushLhs(ts[i])
+			blockStack.PopExpr()
+			appendBody("%s %s = (%s);", ts[i].Cpp(), lhs, v)
+			vs[i] = lhs
 		}
+		return vs, ts
 	}
 
-	// Some stack variables must not be merged when they are used across multiple blocks.
-	nomerge := map[string]struct{}{}
+	// branchArity returns the number of the values that a branch to the block at level carries.
+	branchArity := func(level int) int {
+		_, bl, ok := blockStack.PeepBlockLevel(level)
+		if !ok {
+			return len(sig.ReturnTypes)
+		}
+		if bl.typ == blockTypeLoop {
+			return len(bl.params)
+		}
+		return len(bl.rets)
+	}
+
+	// branch returns the statements to branch to the block at level with the values vs.
+	// A branch to a block assigns the values to the result variables, and a branch to a loop assigns the values to
+	// the parameter variables.
+	branch := func(level int, vs []string) []string {
+		l, bl, ok := blockStack.PeepBlockLevel(level)
+		if !ok {
+			switch len(vs) {
+			case 0:
+				return []string{"return;"}
+			case 1:
+				return []string{fmt.Sprintf("return %s;", vs[0])}
+			default:
+				return []string{fmt.Sprintf("return %s;", resultsExpr(sig.ReturnTypes, vs))}
+			}
+		}
+
+		dsts := bl.rets
+		if bl.typ == blockTypeLoop {
+			dsts = bl.params
+		}
+
+		// The values are assigned in order. This is safe even when the values refer to the loop parameters, as the
+		// i-th parameter can appear only in the values at i or lower.
+		var stmts []string
+		for i, dst := range dsts {
+			if dst != vs[i] {
+				stmts = append(stmts, fmt.Sprintf("%s = %s;", dst, vs[i]))
+			}
+		}
+		return append(stmts, fmt.Sprintf("goto label%d;", l))
+	}
+
+	// unreachable reports whether the current position is unreachable, e.g., after br.
+	var unreachable bool
+
+	// pushResults pushes the members of the struct variable v for multiple return values.
+	pushResults := func(v string, ts []wasm.ValueType) {
+		for i, t := range ts {
+			blockStack.PushExpr(fmt.Sprintf("%s.v%d", v, i), wasmTypeToReturnType(t).stackVarType())
+		}
+	}
 
-	for _, instr := range dis.Code {
-		switch instr.Op.Code {
-		case operators.Unreachable:
+	// popResults pops the n values for the results from the stack.
+	popResults := func(n int) []string {
+		exprs := make([]string, n)
+		for i := n - 1; i >= 0; i-- {
+			exprs[i], _ = blockStack.PopExpr()
+		}
+		return exprs
+	}
+
+	// enterBlock prepares a block of the block type t.
+	// The parameters are moved to new variables, and the variables for the results are declared before the block.
+	enterBlock := func(t int64) (params []string, paramTypes []stackvar.Type, rets []string, err error) {
+		pts, rts, err := f.blockSignature(t)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		exprs := popResults(len(pts))
+		for i, pt := range pts {
+			t := wasmTypeToReturnType(pt)
+			param := blockStack.PushLhs(t.stackVarType())
+			blockStack.PopExpr()
+			// The declaration and the assignment are separated as the declaration is moved to the top.
+			appendBody("%s %s;", t.Cpp(), param)
+			appendBody("%s = %s;", param, exprs[i])
+			nomerge[param] = struct{}{}
+			params = append(params, param)
+			paramTypes = append(paramTypes, t.stackVarType())
+		}
+		for _, rt := range rts {
+			t := wasmTypeToReturnType(rt)
+			ret := blockStack.PushLhs(t.stackVarType())
+			appendBody("%s %s;", t.Cpp(), ret)
+			nomerge[ret] = struct{}{}
+			rets = append(rets, ret)
+		}
+		return params, paramTypes, rets, nil
+	}
+
+	// assignResults assigns the values on the stack to the result variables of the block.
+	// If the end of the block is not reachable, the stack might not have the values.
+	assignResults := func(bl *block) {
+		if len(bl.rets) == 0 || unreachable {
+			return
+		}
+		for i, expr := range popResults(len(bl.rets)) {
+			appendBody("%s = %s;", bl.rets[i], expr)
+		}
+	}
+
+	for _, instr := range code {
+		switch instr.Op {
+		case wasm.OpUnreachable:
 			appendBody(`assert(((void)("not reached"), false));`)
-		case operators.Nop:
+			unreachable = true
+		case wasm.OpNop:
 			// Do nothing
-		case operators.Block:
-			var ret string
-			if t := instr.Immediates[0]; t != wasm.BlockTypeEmpty {
-				t := wasmTypeToReturnType(wasm.ValueType(t.(wasm.BlockType)))
-				ret = blockStack.PushLhs(t.stackVarType())
-				appendBody("%s %s;", t.Cpp(), ret)
-				nomerge[ret] = struct{}{}
-			}
-			blockStack.PushBlock(blockTypeBlock, ret)
-		case operators.Loop:
-			var ret string
-			if t := instr.Immediates[0]; t != wasm.BlockTypeEmpty {
-				t := wasmTypeToReturnType(wasm.ValueType(t.(wasm.BlockType)))
-				ret = blockStack.PushLhs(t.stackVarType())
-				appendBody("%s %s;", t.Cpp(), ret)
-				nomerge[ret] = struct{}{}
+		case wasm.OpBlock:
+			params, paramTypes, rets, err := enterBlock(instr.Immediates[0].(int64))
+			if err != nil {
+				return nil, err
 			}
-			l := blockStack.PushBlock(blockTypeLoop, ret)
+			blockStack.PushBlock(blockTypeBlock, params, paramTypes, rets)
+		case wasm.OpLoop:
+			params, paramTypes, rets, err := enterBlock(instr.Immediates[0].(int64))
+			if err != nil {
+				return nil, err
+			}
+			l := blockStack.PushBlock(blockTypeLoop, params, paramTypes, rets)
 			appendBody("label%d:;", l)
-		case operators.If:
+		case wasm.OpIf:
 			cond, _ := blockStack.PopExpr()
-			var ret string
-			if t := instr.Immediates[0]; t != wasm.BlockTypeEmpty {
-				t := wasmTypeToReturnType(wasm.ValueType(t.(wasm.BlockType)))
-				ret = blockStack.PushLhs(t.stackVarType())
-				appendBody("%s %s;", t.Cpp(), ret)
-				nomerge[ret] = struct{}{}
+			params, paramTypes, rets, err := enterBlock(instr.Immediates[0].(int64))
+			if err != nil {
+				return nil, err
 			}
 			appendBody("if (%s) {", optimizeCondition(cond))
-			blockStack.PushBlock(blockTypeIf, ret)
-		case operators.Else:
-			if _, _, ret := blockStack.PeepBlock(); ret != "" {
-				idx, _ := blockStack.PopExpr()
-				appendBody("%s = %s;", ret, idx)
-			}
+			blockStack.PushBlock(blockTypeIf, params, paramTypes, rets)
+		case wasm.OpElse:
+			_, bl := blockStack.PeepBlock()
+			assignResults(bl)
+			unreachable = false
 			blockStack.UnindentTemporarily()
 			appendBody("} else {")
 			blockStack.IndentTemporarily()
-		case operators.End:
-			if _, btype, ret := blockStack.PeepBlock(); btype != blockTypeLoop && ret != "" {
-				idx, _ := blockStack.PopExpr()
-				appendBody("%s = %s;", ret, idx)
+			// The else branch starts with the parameters again.
+			for blockStack.StackLen() > 0 {
+				blockStack.PopExpr()
+			}
+			bl.hasElse = true
+			bl.pushParams()
+		case wasm.OpEnd:
+			_, bl := blockStack.PeepBlock()
+			assignResults(bl)
+			unreachable = false
+			if bl.typ == blockTypeIf && !bl.hasElse && len(bl.rets) > 0 {
+				// Without else, the parameters are passed through as the results.
+				blockStack.UnindentTemporarily()
+				appendBody("} else {")
+				blockStack.IndentTemporarily()
+				for i, ret := range bl.rets {
+					appendBody("%s = %s;", ret, bl.params[i])
+				}
 			}
-			idx, btype, _ := blockStack.PopBlock()
-			if btype == blockTypeIf {
+			idx, _ := blockStack.PopBlock()
+			if bl.typ == blockTypeIf {
 				appendBody("}")
 			}
-			if btype != blockTypeLoop {
+			if bl.typ != blockTypeLoop {
 				appendBody("label%d:;", idx)
 			}
-		case operators.Br:
-			if _, _, ret := blockStack.PeepBlock(); ret != "" {
-				return nil, fmt.Errorf("br with a returning value is not implemented yet")
-			}
-			level := instr.Immediates[0].(uint32)
-			appendBody(gotoOrReturn(int(level)))
-		case operators.BrIf:
-			if _, _, ret := blockStack.PeepBlock(); ret != "" {
-				return nil, fmt.Errorf("br_if with a returning value is not implemented yet")
+		case wasm.OpBr:
+			level := int(instr.Immediates[0].(uint32))
+			vs, _ := branchValues(branchArity(level), false)
+			for _, stmt := range branch(level, vs) {
+				appendBody(stmt)
 			}
-			level := instr.Immediates[0].(uint32)
+			unreachable = true
+		case wasm.OpBrIf:
+			level := int(instr.Immediates[0].(uint32))
 			expr, _ := blockStack.PopExpr()
+			// The values are kept on the stack as the branch might not be taken.
+			vs, ts := branchValues(branchArity(level), true)
+			for i, v := range vs {
+				blockStack.PushExpr(v, ts[i])
+			}
 			appendBody("if (%s) {", optimizeCondition(expr))
 			blockStack.IndentTemporarily()
-			appendBody(gotoOrReturn(int(level)))
+			for _, stmt := range branch(level, vs) {
+				appendBody(stmt)
+			}
 			blockStack.UnindentTemporarily()
 			appendBody("}")
-		case operators.BrTable:
-			if _, _, ret := blockStack.PeepBlock(); ret != "" {
-				return nil, fmt.Errorf("br_table with a returning value is not implemented yet")
-			}
+		case wasm.OpBrTable:
 			expr, _ := blockStack.PopExpr()
+			n := int(instr.Immediates[0].(uint32))
+			// All the targets have the same arity.
+			vs, _ := branchValues(branchArity(int(instr.Immediates[n+1].(uint32))), true)
 			appendBody("switch (%s) {", expr)
-			len := int(instr.Immediates[0].(uint32))
-			for i := 0; i < len; i++ {
-				level := int(instr.Immediates[1+i].(uint32))
-				gt := gotoOrReturn(int(level))
-				appendBody("case %d: %s", i, gt)
-			}
-			level := int(instr.Immediates[len+1].(uint32))
-			appendBody("default: %s", gotoOrReturn(int(level)))
+			appendCase := func(label string, level int) {
+				stmts := branch(level, vs)
+				if len(stmts) == 1 {
+					appendBody("%s: %s", label, stmts[0])
+					return
+				}
+				appendBody("%s:", label)
+				blockStack.IndentTemporarily()
+				for _, stmt := range stmts {
+					appendBody(stmt)
+				}
+				blockStack.UnindentTemporarily()
+			}
+			for i := 0; i < n; i++ {
+				appendCase(fmt.Sprintf("case %d", i), int(instr.Immediates[1+i].(uint32)))
+			}
+			appendCase("default", int(instr.Immediates[n+1].(uint32)))
 			appendBody("}")
-		case operators.Return:
-			switch len(sig.ReturnTypes) {
+			unreachable = true
+		case wasm.OpReturn:
+			switch n := len(sig.ReturnTypes); n {
 			case 0:
 				appendBody("return;")
-			default:
+			case 1:
 				expr, _ := blockStack.PopExpr()
 				appendBody("return %s;", expr)
+			default:
+				appendBody("return %s;", resultsExpr(sig.ReturnTypes, popResults(n)))
 			}
+			unreachable = true
 
-		case operators.Call:
-			f := funcs[instr.Immediates[0].(uint32)]
+		case wasm.OpCall:
+			fidx := instr.Immediates[0].(uint32)
+			if int(fidx) >= len(funcs) {
+				panicBodyError("invalid function index: %d", fidx)
+			}
+			f := funcs[fidx]
 
 			args := make([]string, len(f.Wasm.Sig.ParamTypes))
 			for i := range f.Wasm.Sig.ParamTypes {
@@ -440,12 +708,16 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 
 			var ret string
-			if n := len(f.Wasm.Sig.ReturnTypes); n > 0 {
-				if n > 1 {
-					return nil, fmt.Errorf("call: unexpected num of return types: %d", n)
-				}
-				t := wasmTypeToReturnType(f.Wasm.Sig.ReturnTypes[0])
+			var results string
+			switch ts := f.Wasm.Sig.ReturnTypes; len(ts) {
+			case 0:
+			case 1:
+				t := wasmTypeToReturnType(ts[0])
 				ret = fmt.Sprintf("%s %s = ", t.Cpp(), blockStack.PushLhs(t.stackVarType()))
+			default:
+				results = fmt.Sprintf("stack0_%d_", tmpidx)
+				tmpidx++
+				ret = fmt.Sprintf("%s %s = ", resultsStructName(ts), results)
 			}
 
 			var imp string
@@ -453,9 +725,15 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				imp = "import_->"
 			}
 			appendBody("%s%s%s(%s);", ret, imp, identifierFromString(f.Wasm.Name), strings.Join(args, ", "))
-		case operators.CallIndirect:
+			if results != "" {
+				pushResults(results, f.Wasm.Sig.ReturnTypes)
+			}
+		case wasm.OpCallIndirect:
 			idx, _ := blockStack.PopExpr()
 			typeid := instr.Immediates[0].(uint32)
+			if int(typeid) >= len(types) {
+				panicBodyError("invalid type index: %d", typeid)
+			}
 			t := types[typeid]
 
 			args := make([]string, len(t.Sig.ParamTypes))
@@ -464,32 +742,41 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				args[len(t.Sig.ParamTypes)-i-1] = fmt.Sprintf("(%s)", expr)
 			}
 
+			fidx := tmpidx
+			tmpidx++
+
 			var ret string
-			if n := len(t.Sig.ReturnTypes); n > 0 {
-				if n > 1 {
-					return nil, fmt.Errorf("call-indirect: unexpected num of return types: %d", n)
-				}
-				t := wasmTypeToReturnType(t.Sig.ReturnTypes[0])
+			var results string
+			switch ts := t.Sig.ReturnTypes; len(ts) {
+			case 0:
+			case 1:
+				t := wasmTypeToReturnType(ts[0])
 				ret = fmt.Sprintf("%s %s = ", t.Cpp(), blockStack.PushLhs(t.stackVarType()))
+			default:
+				results = fmt.Sprintf("stack0_%d_", tmpidx)
+				tmpidx++
+				ret = fmt.Sprintf("%s %s = ", resultsStructName(ts), results)
 			}
 
-			appendBody("Type%d stack0_%d_ = funcs_[table_[0][%s]].type%d_;", typeid, tmpidx, idx, typeid)
-			appendBody("%s(this->*stack0_%d_)(%s);", ret, tmpidx, strings.Join(args, ", "))
-			tmpidx++
+			appendBody("Type%d stack0_%d_ = funcs_[table_[0][%s]].type%d_;", typeid, fidx, idx, typeid)
+			appendBody("%s(this->*stack0_%d_)(%s);", ret, fidx, strings.Join(args, ", "))
+			if results != "" {
+				pushResults(results, t.Sig.ReturnTypes)
+			}
 
-		case operators.Drop:
+		case wasm.OpDrop:
 			blockStack.PopExpr()
-		case operators.Select:
+		case wasm.OpSelect:
 			cond, _ := blockStack.PopExpr()
 			arg1, _ := blockStack.PopExpr()
 			arg0, t := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) ? (%s) : (%s)", optimizeCondition(cond), arg0, arg1), t)
 
-		case operators.GetLocal:
+		case wasm.OpGetLocal:
 			t := f.localVariableType(int(instr.Immediates[0].(uint32)))
 			expr := fmt.Sprintf("local%d_", instr.Immediates[0])
 			blockStack.PushExpr(expr, t.stackVarType())
-		case operators.SetLocal:
+		case wasm.OpSetLocal:
 			lhs := fmt.Sprintf("local%d_", instr.Immediates[0])
 			for _, expr := range blockStack.FlushExprsIfNeeded(lhs) {
 				appendBody(expr)
@@ -498,7 +785,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			if lhs != v {
 				appendBody("%s = %s;", lhs, v)
 			}
-		case operators.TeeLocal:
+		case wasm.OpTeeLocal:
 			lhs := fmt.Sprintf("local%d_", instr.Immediates[0])
 			for _, expr := range blockStack.FlushExprsIfNeeded(lhs) {
 				appendBody(expr)
@@ -510,12 +797,16 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			if lhs != v {
 				appendBody("%s = %s;", lhs, v)
 			}
-		case operators.GetGlobal:
-			g := f.Globals[instr.Immediates[0].(uint32)]
+		case wasm.OpGetGlobal:
+			gidx := instr.Immediates[0].(uint32)
+			if int(gidx) >= len(f.Globals) {
+				panicBodyError("invalid global index: %d", gidx)
+			}
+			g := f.Globals[gidx]
 			t := wasmTypeToReturnType(g.Type)
 			expr := fmt.Sprintf("global%d_", instr.Immediates[0])
 			blockStack.PushExpr(expr, t.stackVarType())
-		case operators.SetGlobal:
+		case wasm.OpSetGlobal:
 			lhs := fmt.Sprintf("global%d_", instr.Immediates[0])
 			for _, expr := range blockStack.FlushExprsIfNeeded(lhs) {
 				appendBody(expr)
@@ -523,7 +814,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			expr, _ := blockStack.PopExpr()
 			appendBody("%s = %s;", lhs, expr)
 
-		case operators.I32Load:
+		case wasm.OpI32Load:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -532,7 +823,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("mem_->LoadInt32((%s)%s)", addr, off)
 			blockStack.PushExpr(expr, stackvar.I32)
-		case operators.I64Load:
+		case wasm.OpI64Load:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -541,7 +832,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("mem_->LoadInt64((%s)%s)", addr, off)
 			blockStack.PushExpr(expr, stackvar.I64)
-		case operators.F32Load:
+		case wasm.OpF32Load:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -550,7 +841,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("mem_->LoadFloat32((%s)%s)", addr, off)
 			blockStack.PushExpr(expr, stackvar.F32)
-		case operators.F64Load:
+		case wasm.OpF64Load:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -559,7 +850,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("mem_->LoadFloat64((%s)%s)", addr, off)
 			blockStack.PushExpr(expr, stackvar.F64)
-		case operators.I32Load8s:
+		case wasm.OpI32Load8s:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -568,7 +859,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int32_t>(mem_->LoadInt8((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I32)
-		case operators.I32Load8u:
+		case wasm.OpI32Load8u:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -577,7 +868,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int32_t>(mem_->LoadUint8((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I32)
-		case operators.I32Load16s:
+		case wasm.OpI32Load16s:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -586,7 +877,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int32_t>(mem_->LoadInt16((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I32)
-		case operators.I32Load16u:
+		case wasm.OpI32Load16u:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -595,7 +886,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int32_t>(mem_->LoadUint16((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I32)
-		case operators.I64Load8s:
+		case wasm.OpI64Load8s:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -604,7 +895,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int64_t>(mem_->LoadInt8((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I64)
-		case operators.I64Load8u:
+		case wasm.OpI64Load8u:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -613,7 +904,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int64_t>(mem_->LoadUint8((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I64)
-		case operators.I64Load16s:
+		case wasm.OpI64Load16s:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -622,7 +913,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int64_t>(mem_->LoadInt16((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I64)
-		case operators.I64Load16u:
+		case wasm.OpI64Load16u:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -631,7 +922,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int64_t>(mem_->LoadUint16((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I64)
-		case operators.I64Load32s:
+		case wasm.OpI64Load32s:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			var off string
@@ -640,13 +931,13 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			expr := fmt.Sprintf("static_cast<int64_t>(mem_->LoadInt32((%s)%s))", addr, off)
 			blockStack.PushExpr(expr, stackvar.I64)
-		case operators.I64Load32u:
+		case wasm.OpI64Load32u:
 			offset := instr.Immediates[1].(uint32)
 			addr, _ := blockStack.PopExpr()
 			expr := fmt.Sprintf("static_cast<int64_t>(mem_->LoadUint32((%s) + %d))", addr, offset)
 			blockStack.PushExpr(expr, stackvar.I64)
 
-		case operators.I32Store:
+		case wasm.OpI32Store:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -658,7 +949,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreInt32((%s)%s, %s);", addr, off, idx)
-		case operators.I64Store:
+		case wasm.OpI64Store:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -670,7 +961,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreInt64((%s)%s, %s);", addr, off, idx)
-		case operators.F32Store:
+		case wasm.OpF32Store:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -682,7 +973,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreFloat32((%s)%s, %s);", addr, off, idx)
-		case operators.F64Store:
+		case wasm.OpF64Store:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -694,7 +985,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreFloat64((%s)%s, %s);", addr, off, idx)
-		case operators.I32Store8:
+		case wasm.OpI32Store8:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -706,7 +997,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreInt8((%s)%s, static_cast<int8_t>(%s));", addr, off, idx)
-		case operators.I32Store16:
+		case wasm.OpI32Store16:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -718,7 +1009,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreInt16((%s)%s, static_cast<int16_t>(%s));", addr, off, idx)
-		case operators.I64Store8:
+		case wasm.OpI64Store8:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -730,7 +1021,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreInt8((%s)%s, static_cast<int8_t>(%s));", addr, off, idx)
-		case operators.I64Store16:
+		case wasm.OpI64Store16:
 			offset := instr.Immediates[1].(uint32)
 			idx, _ := blockStack.PopExpr()
 			addr, _ := blockStack.PopExpr()
@@ -739,7 +1030,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				off = fmt.Sprintf(" + %d", offset)
 			}
 			appendBody("mem_->StoreInt16((%s)%s, static_cast<int16_t>(%s));", addr, off, idx)
-		case operators.I64Store32:
+		case wasm.OpI64Store32:
 			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
 				appendBody(expr)
 			}
@@ -752,24 +1043,24 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			}
 			appendBody("mem_->StoreInt32((%s)%s, static_cast<int32_t>(%s));", addr, off, idx)
 
-		case operators.CurrentMemory:
+		case wasm.OpCurrentMemory:
 			blockStack.PushExpr("mem_->GetSize()", stackvar.I32)
-		case operators.GrowMemory:
+		case wasm.OpGrowMemory:
 			delta, _ := blockStack.PopExpr()
 			// As Grow has side effects, call PushLhs instead of PushExpr.
 			v := blockStack.PushLhs(stackvar.I32)
 			appendBody("int32_t %s = mem_->Grow(%s);", v, delta)
 
-		case operators.I32Const:
+		case wasm.OpI32Const:
 			blockStack.PushExpr(fmt.Sprintf("%d", instr.Immediates[0]), stackvar.I32)
-		case operators.I64Const:
+		case wasm.OpI64Const:
 			if i := instr.Immediates[0].(int64); i == -9223372036854775808 {
 				// C++ cannot represent this value as an integer literal.
 				blockStack.PushExpr(fmt.Sprintf("%dLL - 1LL", i+1), stackvar.I64)
 			} else {
 				blockStack.PushExpr(fmt.Sprintf("%dLL", i), stackvar.I64)
 			}
-		case operators.F32Const:
+		case wasm.OpF32Const:
 			if v := instr.Immediates[0].(float32); v == 0 {
 				blockStack.PushExpr("0.0f", stackvar.F32)
 			} else {
@@ -779,7 +1070,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				appendBody("float %s = *reinterpret_cast<float*>(&stack0_%d_);", va, tmpidx)
 				tmpidx++
 			}
-		case operators.F64Const:
+		case wasm.OpF64Const:
 			if v := instr.Immediates[0].(float64); v == 0 {
 				blockStack.PushExpr("0.0", stackvar.I64)
 			} else {
@@ -790,461 +1081,511 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 				tmpidx++
 			}
 
-		case operators.I32Eqz:
+		case wasm.OpI32Eqz:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) == 0", arg), stackvar.I32)
-		case operators.I32Eq:
+		case wasm.OpI32Eq:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) == (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32Ne:
+		case wasm.OpI32Ne:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) != (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32LtS:
+		case wasm.OpI32LtS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) < (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32LtU:
+		case wasm.OpI32LtU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint32_t>(%s) < static_cast<uint32_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I32GtS:
+		case wasm.OpI32GtS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) > (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32GtU:
+		case wasm.OpI32GtU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint32_t>(%s) > static_cast<uint32_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I32LeS:
+		case wasm.OpI32LeS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) <= (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32LeU:
+		case wasm.OpI32LeU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint32_t>(%s) <= static_cast<uint32_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I32GeS:
+		case wasm.OpI32GeS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) >= (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32GeU:
+		case wasm.OpI32GeU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint32_t>(%s) >= static_cast<uint32_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I64Eqz:
+		case wasm.OpI64Eqz:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) == 0", arg), stackvar.I32)
-		case operators.I64Eq:
+		case wasm.OpI64Eq:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) == (%s)", arg0, arg1), stackvar.I32)
-		case operators.I64Ne:
+		case wasm.OpI64Ne:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) != (%s)", arg0, arg1), stackvar.I32)
-		case operators.I64LtS:
+		case wasm.OpI64LtS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) < (%s)", arg0, arg1), stackvar.I32)
-		case operators.I64LtU:
+		case wasm.OpI64LtU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint64_t>(%s) < static_cast<uint64_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I64GtS:
+		case wasm.OpI64GtS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) > (%s)", arg0, arg1), stackvar.I32)
-		case operators.I64GtU:
+		case wasm.OpI64GtU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint64_t>(%s) > static_cast<uint64_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I64LeS:
+		case wasm.OpI64LeS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) <= (%s)", arg0, arg1), stackvar.I32)
-		case operators.I64LeU:
+		case wasm.OpI64LeU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint64_t>(%s) <= static_cast<uint64_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.I64GeS:
+		case wasm.OpI64GeS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) >= (%s)", arg0, arg1), stackvar.I32)
-		case operators.I64GeU:
+		case wasm.OpI64GeU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<uint64_t>(%s) >= static_cast<uint64_t>(%s)", arg0, arg1), stackvar.I32)
-		case operators.F32Eq:
+		case wasm.OpF32Eq:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) == (%s)", arg0, arg1), stackvar.I32)
-		case operators.F32Ne:
+		case wasm.OpF32Ne:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) != (%s)", arg0, arg1), stackvar.I32)
-		case operators.F32Lt:
+		case wasm.OpF32Lt:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) < (%s)", arg0, arg1), stackvar.I32)
-		case operators.F32Gt:
+		case wasm.OpF32Gt:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) > (%s)", arg0, arg1), stackvar.I32)
-		case operators.F32Le:
+		case wasm.OpF32Le:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) <= (%s)", arg0, arg1), stackvar.I32)
-		case operators.F32Ge:
+		case wasm.OpF32Ge:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) >= (%s)", arg0, arg1), stackvar.I32)
-		case operators.F64Eq:
+		case wasm.OpF64Eq:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) == (%s)", arg0, arg1), stackvar.I32)
-		case operators.F64Ne:
+		case wasm.OpF64Ne:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) != (%s)", arg0, arg1), stackvar.I32)
-		case operators.F64Lt:
+		case wasm.OpF64Lt:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) < (%s)", arg0, arg1), stackvar.I32)
-		case operators.F64Gt:
+		case wasm.OpF64Gt:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) > (%s)", arg0, arg1), stackvar.I32)
-		case operators.F64Le:
+		case wasm.OpF64Le:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) <= (%s)", arg0, arg1), stackvar.I32)
-		case operators.F64Ge:
+		case wasm.OpF64Ge:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) >= (%s)", arg0, arg1), stackvar.I32)
 
-		case operators.I32Clz:
+		case wasm.OpI32Clz:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("Bits::LeadingZeros(static_cast<uint32_t>(%s))", arg), stackvar.I32)
-		case operators.I32Ctz:
+		case wasm.OpI32Ctz:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("Bits::TailingZeros(static_cast<uint32_t>(%s))", arg), stackvar.I32)
-		case operators.I32Popcnt:
+		case wasm.OpI32Popcnt:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("Bits::OnesCount(static_cast<uint32_t>(%s))", arg), stackvar.I32)
-		case operators.I32Add:
+		case wasm.OpI32Add:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) + (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32Sub:
+		case wasm.OpI32Sub:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) - (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32Mul:
+		case wasm.OpI32Mul:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) * (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32DivS:
+		case wasm.OpI32DivS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) / (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32DivU:
+		case wasm.OpI32DivU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<uint32_t>(%s) / static_cast<uint32_t>(%s))", arg0, arg1), stackvar.I32)
-		case operators.I32RemS:
+		case wasm.OpI32RemS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) %% (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32RemU:
+		case wasm.OpI32RemU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<uint32_t>(%s) %% static_cast<uint32_t>(%s))", arg0, arg1), stackvar.I32)
-		case operators.I32And:
+		case wasm.OpI32And:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) & (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32Or:
+		case wasm.OpI32Or:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) | (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32Xor:
+		case wasm.OpI32Xor:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) ^ (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32Shl:
+		case wasm.OpI32Shl:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) << (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32ShrS:
+		case wasm.OpI32ShrS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) >> (%s)", arg0, arg1), stackvar.I32)
-		case operators.I32ShrU:
+		case wasm.OpI32ShrU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<uint32_t>(%s) >> (%s))", arg0, arg1), stackvar.I32)
-		case operators.I32Rotl:
+		case wasm.OpI32Rotl:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(Bits::RotateLeft(static_cast<uint32_t>(%s), static_cast<int32_t>(%s)))", arg0, arg1), stackvar.I32)
-		case operators.I32Rotr:
+		case wasm.OpI32Rotr:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(Bits::RotateLeft(static_cast<uint32_t>(%s), -static_cast<int32_t>(%s)))", arg0, arg1), stackvar.I32)
-		case operators.I64Clz:
+		case wasm.OpI64Clz:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(Bits::LeadingZeros(static_cast<uint64_t>(%s)))", arg), stackvar.I64)
-		case operators.I64Ctz:
+		case wasm.OpI64Ctz:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(Bits::TailingZeros(static_cast<uint64_t>(%s)))", arg), stackvar.I64)
-		case operators.I64Popcnt:
+		case wasm.OpI64Popcnt:
 			arg, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(Bits::OnesCount(static_cast<uint64_t>(%s)))", arg), stackvar.I64)
-		case operators.I64Add:
+		case wasm.OpI64Add:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) + (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64Sub:
+		case wasm.OpI64Sub:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) - (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64Mul:
+		case wasm.OpI64Mul:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) * (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64DivS:
+		case wasm.OpI64DivS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) / (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64DivU:
+		case wasm.OpI64DivU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<uint64_t>(%s) / static_cast<uint64_t>(%s))", arg0, arg1), stackvar.I64)
-		case operators.I64RemS:
+		case wasm.OpI64RemS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) %% (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64RemU:
+		case wasm.OpI64RemU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<uint64_t>(%s) %% static_cast<uint64_t>(%s))", arg0, arg1), stackvar.I64)
-		case operators.I64And:
+		case wasm.OpI64And:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) & (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64Or:
+		case wasm.OpI64Or:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) | (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64Xor:
+		case wasm.OpI64Xor:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) ^ (%s)", arg0, arg1), stackvar.I64)
-		case operators.I64Shl:
+		case wasm.OpI64Shl:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) << static_cast<int32_t>(%s)", arg0, arg1), stackvar.I64)
-		case operators.I64ShrS:
+		case wasm.OpI64ShrS:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) >> static_cast<int32_t>(%s)", arg0, arg1), stackvar.I64)
-		case operators.I64ShrU:
+		case wasm.OpI64ShrU:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<uint64_t>(%s) >> static_cast<int32_t>(%s))", arg0, arg1), stackvar.I64)
-		case operators.I64Rotl:
+		case wasm.OpI64Rotl:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(Bits::RotateLeft(static_cast<uint64_t>(%s), static_cast<int32_t>(%s)))", arg0, arg1), stackvar.I64)
-		case operators.I64Rotr:
+		case wasm.OpI64Rotr:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(Bits::RotateLeft(static_cast<uint64_t>(%s), -(static_cast<int32_t>(%s))))", arg0, arg1), stackvar.I64)
-		case operators.F32Abs:
+		case wasm.OpF32Abs:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::abs(%s)", expr), stackvar.F32)
-		case operators.F32Neg:
+		case wasm.OpF32Neg:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("-(%s)", expr), stackvar.F32)
-		case operators.F32Ceil:
+		case wasm.OpF32Ceil:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::ceil(%s)", expr), stackvar.F32)
-		case operators.F32Floor:
+		case wasm.OpF32Floor:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::floor(%s)", expr), stackvar.F32)
-		case operators.F32Trunc:
+		case wasm.OpF32Trunc:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::trunc(%s)", expr), stackvar.F32)
-		case operators.F32Nearest:
+		case wasm.OpF32Nearest:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("Math::Round(%s)", expr), stackvar.F32)
-		case operators.F32Sqrt:
+		case wasm.OpF32Sqrt:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::sqrt(%s)", expr), stackvar.F32)
-		case operators.F32Add:
+		case wasm.OpF32Add:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) + (%s)", arg0, arg1), stackvar.F32)
-		case operators.F32Sub:
+		case wasm.OpF32Sub:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) - (%s)", arg0, arg1), stackvar.F32)
-		case operators.F32Mul:
+		case wasm.OpF32Mul:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) * (%s)", arg0, arg1), stackvar.F32)
-		case operators.F32Div:
+		case wasm.OpF32Div:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) / (%s)", arg0, arg1), stackvar.F32)
-		case operators.F32Min:
+		case wasm.OpF32Min:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::min((%s), (%s))", arg0, arg1), stackvar.F32)
-		case operators.F32Max:
+		case wasm.OpF32Max:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::max((%s), (%s))", arg0, arg1), stackvar.F32)
-		case operators.F32Copysign:
+		case wasm.OpF32Copysign:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::copysign((%s), (%s))", arg0, arg1), stackvar.F32)
-		case operators.F64Abs:
+		case wasm.OpF64Abs:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::abs(%s)", expr), stackvar.F64)
-		case operators.F64Neg:
+		case wasm.OpF64Neg:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("-(%s)", expr), stackvar.F64)
-		case operators.F64Ceil:
+		case wasm.OpF64Ceil:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::ceil(%s)", expr), stackvar.F64)
-		case operators.F64Floor:
+		case wasm.OpF64Floor:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::floor(%s)", expr), stackvar.F64)
-		case operators.F64Trunc:
+		case wasm.OpF64Trunc:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::trunc(%s)", expr), stackvar.F64)
-		case operators.F64Nearest:
+		case wasm.OpF64Nearest:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("Math::Round(%s)", expr), stackvar.F64)
-		case operators.F64Sqrt:
+		case wasm.OpF64Sqrt:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::sqrt(%s)", expr), stackvar.F64)
-		case operators.F64Add:
+		case wasm.OpF64Add:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) + (%s)", arg0, arg1), stackvar.F64)
-		case operators.F64Sub:
+		case wasm.OpF64Sub:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) - (%s)", arg0, arg1), stackvar.F64)
-		case operators.F64Mul:
+		case wasm.OpF64Mul:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) * (%s)", arg0, arg1), stackvar.F64)
-		case operators.F64Div:
+		case wasm.OpF64Div:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("(%s) / (%s)", arg0, arg1), stackvar.F64)
-		case operators.F64Min:
+		case wasm.OpF64Min:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::min((%s), (%s))", arg0, arg1), stackvar.F64)
-		case operators.F64Max:
+		case wasm.OpF64Max:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::max((%s), (%s))", arg0, arg1), stackvar.F64)
-		case operators.F64Copysign:
+		case wasm.OpF64Copysign:
 			arg1, _ := blockStack.PopExpr()
 			arg0, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("std::copysign((%s), (%s))", arg0, arg1), stackvar.F64)
 
-		case operators.I32WrapI64:
+		case wasm.OpI32WrapI64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(%s)", expr), stackvar.I32)
-		case operators.I32TruncSF32:
+		case wasm.OpI32TruncSF32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(std::trunc(%s))", expr), stackvar.I32)
-		case operators.I32TruncUF32:
+		case wasm.OpI32TruncUF32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<uint32_t>(std::trunc(%s)))", expr), stackvar.I32)
-		case operators.I32TruncSF64:
+		case wasm.OpI32TruncSF64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(std::trunc(%s))", expr), stackvar.I32)
-		case operators.I32TruncUF64:
+		case wasm.OpI32TruncUF64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<uint32_t>(std::trunc(%s)))", expr), stackvar.I32)
-		case operators.I64ExtendSI32:
+		case wasm.OpI64ExtendSI32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(%s)", expr), stackvar.I64)
-		case operators.I64ExtendUI32:
+		case wasm.OpI64ExtendUI32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<uint32_t>(%s))", expr), stackvar.I64)
-		case operators.I64TruncSF32:
+		case wasm.OpI64TruncSF32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(std::trunc(%s))", expr), stackvar.I64)
-		case operators.I64TruncUF32:
+		case wasm.OpI64TruncUF32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<uint64_t>(std::trunc(%s)))", expr), stackvar.I64)
-		case operators.I64TruncSF64:
+		case wasm.OpI64TruncSF64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(std::trunc(%s))", expr), stackvar.I64)
-		case operators.I64TruncUF64:
+		case wasm.OpI64TruncUF64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<uint64_t>(std::trunc(%s)))", expr), stackvar.I64)
-		case operators.F32ConvertSI32:
+		case wasm.OpF32ConvertSI32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<float>(%s)", expr), stackvar.F32)
-		case operators.F32ConvertUI32:
+		case wasm.OpF32ConvertUI32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<float>(static_cast<uint32_t>(%s))", expr), stackvar.F32)
-		case operators.F32ConvertSI64:
+		case wasm.OpF32ConvertSI64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<float>(%s)", expr), stackvar.F32)
-		case operators.F32ConvertUI64:
+		case wasm.OpF32ConvertUI64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<float>(static_cast<uint64_t>((%s)))", expr), stackvar.F32)
-		case operators.F32DemoteF64:
+		case wasm.OpF32DemoteF64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<float>(%s)", expr), stackvar.F32)
-		case operators.F64ConvertSI32:
+		case wasm.OpF64ConvertSI32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<double>(%s)", expr), stackvar.F64)
-		case operators.F64ConvertUI32:
+		case wasm.OpF64ConvertUI32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<double>(static_cast<uint32_t>(%s))", expr), stackvar.F64)
-		case operators.F64ConvertSI64:
+		case wasm.OpF64ConvertSI64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<double>(%s)", expr), stackvar.F64)
-		case operators.F64ConvertUI64:
+		case wasm.OpF64ConvertUI64:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<double>(static_cast<uint64_t>(%s))", expr), stackvar.F64)
-		case operators.F64PromoteF32:
+		case wasm.OpF64PromoteF32:
 			expr, _ := blockStack.PopExpr()
 			blockStack.PushExpr(fmt.Sprintf("static_cast<double>(%s)", expr), stackvar.F64)
 
-		case operators.I32ReinterpretF32:
-			return nil, fmt.Errorf("I32ReinterpretF32 is not implemented yet")
-		case operators.I64ReinterpretF64:
-			return nil, fmt.Errorf("I64ReinterpretF64 is not implemented yet")
-		case operators.F32ReinterpretI32:
-			return nil, fmt.Errorf("F32ReinterpretI32 is not implemented yet")
-		case operators.F64ReinterpretI64:
-			return nil, fmt.Errorf("F64ReinterpretI64 is not implemented yet")
+		case wasm.OpI32Extend8S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<int8_t>(%s))", expr), stackvar.I32)
+		case wasm.OpI32Extend16S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("static_cast<int32_t>(static_cast<int16_t>(%s))", expr), stackvar.I32)
+		case wasm.OpI64Extend8S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<int8_t>(%s))", expr), stackvar.I64)
+		case wasm.OpI64Extend16S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<int16_t>(%s))", expr), stackvar.I64)
+		case wasm.OpI64Extend32S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("static_cast<int64_t>(static_cast<int32_t>(%s))", expr), stackvar.I64)
+
+		case wasm.OpI32TruncSatF32S, wasm.OpI32TruncSatF64S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Math::TruncSatInt32(%s)", expr), stackvar.I32)
+		case wasm.OpI32TruncSatF32U, wasm.OpI32TruncSatF64U:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Math::TruncSatUint32(%s)", expr), stackvar.I32)
+		case wasm.OpI64TruncSatF32S, wasm.OpI64TruncSatF64S:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Math::TruncSatInt64(%s)", expr), stackvar.I64)
+		case wasm.OpI64TruncSatF32U, wasm.OpI64TruncSatF64U:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Math::TruncSatUint64(%s)", expr), stackvar.I64)
+		case wasm.OpMemoryCopy:
+			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
+				appendBody(expr)
+			}
+			n, _ := blockStack.PopExpr()
+			src, _ := blockStack.PopExpr()
+			dst, _ := blockStack.PopExpr()
+			appendBody("mem_->Memmove(%s, %s, %s);", dst, src, n)
+		case wasm.OpMemoryFill:
+			for _, expr := range blockStack.FlushExprsIfNeeded("mem_->") {
+				appendBody(expr)
+			}
+			n, _ := blockStack.PopExpr()
+			val, _ := blockStack.PopExpr()
+			dst, _ := blockStack.PopExpr()
+			appendBody("mem_->Memset(%s, %s, %s);", dst, val, n)
+
+		case wasm.OpI32ReinterpretF32:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Bits::Float32ToBits(%s)", expr), stackvar.I32)
+		case wasm.OpI64ReinterpretF64:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Bits::Float64ToBits(%s)", expr), stackvar.I64)
+		case wasm.OpF32ReinterpretI32:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Bits::Float32FromBits(%s)", expr), stackvar.F32)
+		case wasm.OpF64ReinterpretI64:
+			expr, _ := blockStack.PopExpr()
+			blockStack.PushExpr(fmt.Sprintf("Bits::Float64FromBits(%s)", expr), stackvar.F64)
 
 		default:
 			return nil, fmt.Errorf("unexpected operator: %v", instr.Op)
 		}
 	}
 
-	switch len(sig.ReturnTypes) {
+	lastUnreachable := len(code) > 0 && code[len(code)-1].Op == wasm.OpUnreachable
+	switch n := len(sig.ReturnTypes); n {
 	case 0:
 		// Do nothing.
 	case 1:
-		if !blockStack.IsStackVarEmpty() && dis.Code[len(dis.Code)-1].Op.Code != operators.Unreachable {
+		if !blockStack.IsStackVarEmpty() && !lastUnreachable {
 			if len(body) == 0 || !strings.HasPrefix(strings.TrimSpace(body[len(body)-1]), "return ") {
 				expr, _ := blockStack.PopExpr()
 				appendBody(`return %s;`, expr)
@@ -1255,7 +1596,14 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 			appendBody(`return 0;`)
 		}
 	default:
-		return nil, fmt.Errorf("unexpected num of return types: %d", len(sig.ReturnTypes))
+		if blockStack.StackLen() >= n && !lastUnreachable {
+			if len(body) == 0 || !strings.HasPrefix(strings.TrimSpace(body[len(body)-1]), "return ") {
+				appendBody(`return %s;`, resultsExpr(sig.ReturnTypes, popResults(n)))
+			}
+		} else {
+			appendBody(`assert(((void)("not reached"), false));`)
+			appendBody(`return %s{};`, resultsStructName(sig.ReturnTypes))
+		}
 	}
 
 	body = aggregateStackVars(body, nomerge)
@@ -1267,7 +1615,7 @@ func (f *wasmFunc) bodyToCpp() ([]string, error) {
 
 var (
 	stackVarRe     = regexp.MustCompile(`stack[0-9]+_[0-9]+_`)
-	stackVarDeclRe = regexp.MustCompile(`^\s*((int32_t|int64_t|uint32_t|uint64_t|float|double|Type[0-9]+) (stack([0-9]+)_[0-9]+_))`)
+	stackVarDeclRe = regexp.MustCompile(`^\s*((int32_t|int64_t|uint32_t|uint64_t|float|double|Type[0-9]+|Results[A-Z0-9]+) (stack([0-9]+)_[0-9]+_))`)
 )
 
 func aggregateStackVars(body []string, nomerge map[string]struct{}) []string {
@@ -1289,6 +1637,10 @@ func aggregateStackVars(body []string, nomerge map[string]struct{}) []string {
 		case "double":
 			tname = "f64"
 		default:
+			if strings.HasPrefix(t, "Results") {
+				tname = "r" + strings.ToLower(t[len("Results"):])
+				break
+			}
 			tname = "t" + t[4:]
 		}
 		return fmt.Sprintf("%s_%d_", tname, idx)
@@ -1422,27 +1774,32 @@ func optimizeGoto(body []string) []string {
 			continue
 		}
 
-		brtableLocal = m1[1]
-		brtableStartLabel = m2[1]
-		brtableValueToDst = map[int]string{}
-
-		i++
-		for {
-			m := caseGotoRe.FindStringSubmatch(body[i])
+		valueToDst := map[int]string{}
+		var defaultDst string
+		for j := i + 1; j < len(body); j++ {
+			m := caseGotoRe.FindStringSubmatch(body[j])
 			if m == nil {
+				// A case with multiple statements, e.g., a branch with values, is not optimized.
 				break
 			}
 			if m[1] == "default" {
-				brtableDefaultDst = m[3]
+				defaultDst = m[3]
 				break
 			}
 			v, err := strconv.Atoi(m[2])
 			if err != nil {
 				panic(err)
 			}
-			brtableValueToDst[v] = m[3]
-			i++
+			valueToDst[v] = m[3]
 		}
+		if defaultDst == "" {
+			continue
+		}
+
+		brtableLocal = m1[1]
+		brtableStartLabel = m2[1]
+		brtableDefaultDst = defaultDst
+		brtableValueToDst = valueToDst
 		break
 	}
 
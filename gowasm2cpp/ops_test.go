@@ -0,0 +1,326 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+var (
+	i32 = wasm.ValueTypeI32
+	i64 = wasm.ValueTypeI64
+	f32 = wasm.ValueTypeF32
+	f64 = wasm.ValueTypeF64
+)
+
+type opsTestCase struct {
+	Name    string
+	Params  []wasm.ValueType
+	Results []wasm.ValueType
+
+	// Types is the function types that block types can refer to.
+	Types []wasm.FunctionSig
+
+	// Code is the function body without the last end.
+	Code []byte
+}
+
+// testBodyToCppGolden converts the function bodies and compares the results with testdata/ops/<name>.golden.
+// Run `go test -update` to update the golden files.
+func testBodyToCppGolden(t *testing.T, testCases []opsTestCase) {
+	for _, tc := range testCases {
+		var types []*wasmType
+		for i := range tc.Types {
+			types = append(types, &wasmType{
+				Sig:   &tc.Types[i],
+				Index: i,
+			})
+		}
+		f := &wasmFunc{
+			Wasm: wasm.Function{
+				Sig: &wasm.FunctionSig{
+					ParamTypes:  tc.Params,
+					ReturnTypes: tc.Results,
+				},
+				Body: &wasm.FunctionBody{
+					Code: tc.Code,
+				},
+				Name: tc.Name,
+			},
+			Types: types,
+		}
+		lines, err := f.bodyToCpp()
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		got := strings.Join(lines, "\n") + "\n"
+
+		path := filepath.Join("testdata", "ops", tc.Name+".golden")
+		if *updateGolden {
+			if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tc.Name, got, want)
+		}
+	}
+}
+
+func TestReinterpret(t *testing.T) {
+	testBodyToCppGolden(t, []opsTestCase{
+		{
+			Name:    "i32_reinterpret_f32",
+			Params:  []wasm.ValueType{f32},
+			Results: []wasm.ValueType{i32},
+			Code:    []byte{0x20, 0x00, 0xbc},
+		},
+		{
+			Name:    "i64_reinterpret_f64",
+			Params:  []wasm.ValueType{f64},
+			Results: []wasm.ValueType{i64},
+			Code:    []byte{0x20, 0x00, 0xbd},
+		},
+		{
+			Name:    "f32_reinterpret_i32",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{f32},
+			Code:    []byte{0x20, 0x00, 0xbe},
+		},
+		{
+			Name:    "f64_reinterpret_i64",
+			Params:  []wasm.ValueType{i64},
+			Results: []wasm.ValueType{f64},
+			Code:    []byte{0x20, 0x00, 0xbf},
+		},
+		{
+			// math.Abs: Float64frombits(Float64bits(x) &^ (1 << 63))
+			Name:    "f64_abs_via_bits",
+			Params:  []wasm.ValueType{f64},
+			Results: []wasm.ValueType{f64},
+			Code: []byte{
+				0x20, 0x00, 0xbd, // local.get 0; i64.reinterpret_f64
+				0x42, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, // i64.const 0x7fffffffffffffff
+				0x83, 0xbf, // i64.and; f64.reinterpret_i64
+			},
+		},
+	})
+}
+
+func TestSignExtension(t *testing.T) {
+	testBodyToCppGolden(t, []opsTestCase{
+		{
+			Name:    "i32_extend8_s",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code:    []byte{0x20, 0x00, 0xc0},
+		},
+		{
+			Name:    "i32_extend16_s",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code:    []byte{0x20, 0x00, 0xc1},
+		},
+		{
+			Name:    "i64_extend8_s",
+			Params:  []wasm.ValueType{i64},
+			Results: []wasm.ValueType{i64},
+			Code:    []byte{0x20, 0x00, 0xc2},
+		},
+		{
+			Name:    "i64_extend16_s",
+			Params:  []wasm.ValueType{i64},
+			Results: []wasm.ValueType{i64},
+			Code:    []byte{0x20, 0x00, 0xc3},
+		},
+		{
+			Name:    "i64_extend32_s",
+			Params:  []wasm.ValueType{i64},
+			Results: []wasm.ValueType{i64},
+			Code:    []byte{0x20, 0x00, 0xc4},
+		},
+	})
+}
+
+func TestSaturatingTruncation(t *testing.T) {
+	var testCases []opsTestCase
+	for i, name := range []string{
+		"i32_trunc_sat_f32_s",
+		"i32_trunc_sat_f32_u",
+		"i32_trunc_sat_f64_s",
+		"i32_trunc_sat_f64_u",
+		"i64_trunc_sat_f32_s",
+		"i64_trunc_sat_f32_u",
+		"i64_trunc_sat_f64_s",
+		"i64_trunc_sat_f64_u",
+	} {
+		param := f32
+		if i&2 != 0 {
+			param = f64
+		}
+		result := i32
+		if i&4 != 0 {
+			result = i64
+		}
+		testCases = append(testCases, opsTestCase{
+			Name:    name,
+			Params:  []wasm.ValueType{param},
+			Results: []wasm.ValueType{result},
+			Code:    []byte{0x20, 0x00, 0xfc, byte(i)},
+		})
+	}
+	testBodyToCppGolden(t, testCases)
+}
+
+func TestBulkMemory(t *testing.T) {
+	testBodyToCppGolden(t, []opsTestCase{
+		{
+			Name:   "memory_copy",
+			Params: []wasm.ValueType{i32, i32, i32},
+			Code:   []byte{0x20, 0x00, 0x20, 0x01, 0x20, 0x02, 0xfc, 0x0a, 0x00, 0x00},
+		},
+		{
+			Name:   "memory_fill",
+			Params: []wasm.ValueType{i32, i32, i32},
+			Code:   []byte{0x20, 0x00, 0x20, 0x01, 0x20, 0x02, 0xfc, 0x0b, 0x00},
+		},
+		{
+			// The load before memory.fill must be evaluated before filling.
+			Name:    "memory_fill_after_load",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x20, 0x00, 0x28, 0x02, 0x00, // local.get 0; i32.load
+				0x20, 0x00, 0x41, 0x00, 0x41, 0x04, 0xfc, 0x0b, 0x00, // memory.fill(local0, 0, 4)
+			},
+		},
+	})
+}
+
+func TestBranchWithValues(t *testing.T) {
+	testBodyToCppGolden(t, []opsTestCase{
+		{
+			Name:    "br_block_result",
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x02, 0x7f, // block (result i32)
+				0x41, 0x01, 0x41, 0x07, 0x0c, 0x00, // i32.const 1; i32.const 7; br 0
+				0x0b, // end
+			},
+		},
+		{
+			Name:    "br_outer_block_result",
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x02, 0x7f, 0x02, 0x40, // block (result i32); block
+				0x41, 0x05, 0x0c, 0x01, // i32.const 5; br 1
+				0x0b, 0x41, 0x09, 0x0b, // end; i32.const 9; end
+			},
+		},
+		{
+			Name:    "br_if_block_result",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x02, 0x7f, // block (result i32)
+				0x41, 0x0a, 0x20, 0x00, 0x0d, 0x00, 0x1a, // i32.const 10; local.get 0; br_if 0; drop
+				0x41, 0x14, 0x0b, // i32.const 20; end
+			},
+		},
+		{
+			Name:    "br_if_outer_block_result",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x02, 0x7f, 0x02, 0x40, // block (result i32); block
+				0x41, 0x1e, 0x20, 0x00, 0x0d, 0x01, 0x1a, // i32.const 30; local.get 0; br_if 1; drop
+				0x0b, 0x41, 0x28, 0x0b, // end; i32.const 40; end
+			},
+		},
+		{
+			Name:    "br_if_function_result",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x20, 0x00, 0x04, 0x40, // local.get 0; if
+				0x41, 0x0b, 0x41, 0x01, 0x0d, 0x01, 0x1a, // i32.const 11; i32.const 1; br_if 1; drop
+				0x0b, 0x41, 0x0c, // end; i32.const 12
+			},
+		},
+		{
+			Name:    "br_table_block_result",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x02, 0x7f, 0x02, 0x7f, 0x02, 0x7f, // block (result i32) x3
+				0x41, 0xe4, 0x00, 0x20, 0x00, 0x0e, 0x02, 0x00, 0x01, 0x02, // i32.const 100; local.get 0; br_table 0 1 2
+				0x0b, 0x41, 0x01, 0x6a, // end; i32.const 1; i32.add
+				0x0b, 0x41, 0x02, 0x6a, // end; i32.const 2; i32.add
+				0x0b, // end
+			},
+		},
+		{
+			Name:    "br_table_multi_value",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Types: []wasm.FunctionSig{
+				{ReturnTypes: []wasm.ValueType{i32, i32}},
+			},
+			Code: []byte{
+				0x02, 0x00, 0x02, 0x00, // block (type 0); block (type 0)
+				0x41, 0x03, 0x41, 0x04, 0x20, 0x00, 0x0e, 0x01, 0x00, 0x01, // i32.const 3; i32.const 4; local.get 0; br_table 0 1
+				0x0b, 0x41, 0xe4, 0x00, 0x6a, // end; i32.const 100; i32.add
+				0x0b, 0x6c, // end; i32.mul
+			},
+		},
+		{
+			// The sum of 1 to local 0. local 1 is used as a temporary variable.
+			Name:    "br_if_loop_params",
+			Params:  []wasm.ValueType{i32, i32},
+			Results: []wasm.ValueType{i32},
+			Types: []wasm.FunctionSig{
+				{ParamTypes: []wasm.ValueType{i32, i32}, ReturnTypes: []wasm.ValueType{i32}},
+			},
+			Code: []byte{
+				0x41, 0x00, 0x20, 0x00, 0x03, 0x00, // i32.const 0; local.get 0; loop (type 0)
+				0x21, 0x01, 0x20, 0x01, 0x6a, // local.set 1; local.get 1; i32.add
+				0x20, 0x01, 0x41, 0x01, 0x6b, // local.get 1; i32.const 1; i32.sub
+				0x20, 0x01, 0x41, 0x01, 0x4a, 0x0d, 0x00, // local.get 1; i32.const 1; i32.gt_s; br_if 0
+				0x1a, 0x0b, // drop; end
+			},
+		},
+		{
+			Name:    "br_in_if_result",
+			Params:  []wasm.ValueType{i32},
+			Results: []wasm.ValueType{i32},
+			Code: []byte{
+				0x20, 0x00, 0x04, 0x7f, // local.get 0; if (result i32)
+				0x41, 0x15, 0x0c, 0x00, // i32.const 21; br 0
+				0x05, 0x41, 0x16, 0x0b, // else; i32.const 22; end
+			},
+		},
+		{
+			Name:    "br_function_multi_value",
+			Results: []wasm.ValueType{i32, i64},
+			Code: []byte{
+				0x02, 0x40, 0x02, 0x40, // block; block
+				0x41, 0x01, 0x42, 0x02, 0x0c, 0x02, // i32.const 1; i64.const 2; br 2
+				0x0b, 0x0b, 0x00, // end; end; unreachable
+			},
+		},
+	})
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// staleFileRemover is implemented by an Output that can remove files generated previously.
+type staleFileRemover interface {
+	// removeStaleFiles removes the generated files that match pattern and are not in names.
+	removeStaleFiles(pattern string, names map[string]struct{}) error
+}
+
+// manifestReader is implemented by an Output that can read the manifest generated previously.
+type manifestReader interface {
+	// readManifest returns the manifest generated previously, or nil if there is no manifest.
+	readManifest() (*Manifest, error)
+}
+
+// output writes generated files to an Output.
+type output struct {
+	ctx  context.Context
+	sink Output
+
+	// unity is the number of amalgamated C++ files. If unity is 0, the C++ files are written as they are.
+	unity int
+
+	names     map[string]struct{}
+	unitySrcs []unitySource
+	m         sync.Mutex
+}
+
+func newOutput(ctx context.Context, sink Output, unity int) *output {
+	return &output{
+		ctx:   ctx,
+		sink:  sink,
+		unity: unity,
+		names: map[string]struct{}{},
+	}
+}
+
+// writeFile executes tmpl with data and writes the result to the file name.
+func (o *output) writeFile(name string, tmpl *template.Template, data interface{}) error {
+	if err := o.ctx.Err(); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	return o.write(name, buf.Bytes())
+}
+
+// write writes content to the file name.
+//
+// In the unity mode, C++ sources are not written until flushUnity is called.
+func (o *output) write(name string, content []byte) error {
+	if o.unity > 0 && strings.HasSuffix(name, ".cpp") {
+		// The OpenGL sources are kept separated so that the build files can still build them as an optional target.
+		if _, ok := glSources[name]; !ok {
+			o.m.Lock()
+			o.unitySrcs = append(o.unitySrcs, unitySource{
+				name:    name,
+				content: content,
+			})
+			o.m.Unlock()
+			return nil
+		}
+	}
+	return o.writeToSink(name, content)
+}
+
+// writeToSink writes content to the file name immediately.
+func (o *output) writeToSink(name string, content []byte) error {
+	if err := o.ctx.Err(); err != nil {
+		return err
+	}
+
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.names[name] = struct{}{}
+	return o.sink.WriteFile(name, content)
+}
+
+// flushUnity writes the amalgamated C++ files in the unity mode.
+func (o *output) flushUnity() error {
+	o.m.Lock()
+	srcs := o.unitySrcs
+	o.unitySrcs = nil
+	o.m.Unlock()
+
+	if len(srcs) == 0 {
+		return nil
+	}
+	units := amalgamate(srcs, o.unity)
+	var names []string
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := o.writeToSink(name, units[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// files returns the sorted names of the written files.
+func (o *output) files() []string {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	var names []string
+	for n := range o.names {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removeStaleFiles removes the generated files that match pattern and are not written by o, if the Output supports
+// it. This is useful to remove files generated previously, e.g., when the number of functions decreases.
+func (o *output) removeStaleFiles(pattern string) error {
+	r, ok := o.sink.(staleFileRemover)
+	if !ok {
+		return nil
+	}
+
+	o.m.Lock()
+	defer o.m.Unlock()
+	return r.removeStaleFiles(pattern, o.names)
+}
+
+// checkMode returns an error if the Output has the files generated previously only for the other of the runtime and
+// the program. Both the runtime and the program write the manifest and remove the other's files as stale, so they
+// cannot share one directory.
+func (o *output) checkMode(mode string) error {
+	r, ok := o.sink.(manifestReader)
+	if !ok {
+		return nil
+	}
+	m, err := r.readManifest()
+	if err != nil {
+		return err
+	}
+	if m == nil || m.Mode == "" || mode == "" || m.Mode == mode {
+		return nil
+	}
+	return fmt.Errorf("gowasm2cpp: the output already has the %s files: generate the runtime and the program to different directories", m.Mode)
+}
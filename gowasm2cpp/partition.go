@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Partition represents a strategy to split functions into C++ files.
+type Partition string
+
+const (
+	// PartitionIndex splits functions into groups of a fixed size in the order of their indices.
+	// The generated files are named inst.funcsN.cpp.
+	PartitionIndex Partition = "index"
+
+	// PartitionPackage splits functions by their Go packages.
+	// The generated files are named after the packages like inst.runtime.cpp.
+	// A large package is split into several buckets whose total sizes of the Wasm code are balanced.
+	// Changing a function can move other functions of the same package between the buckets, but never affects the
+	// files of the other packages.
+	PartitionPackage Partition = "package"
+)
+
+const defaultGroupSize = 64
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type funcGroup struct {
+	Name  string
+	Funcs []*wasmFunc
+}
+
+func partitionFuncs(funcs []*wasmFunc, partition Partition, groupSize int) ([]funcGroup, error) {
+	if groupSize <= 0 {
+		groupSize = defaultGroupSize
+	}
+
+	switch partition {
+	case "", PartitionIndex:
+		var groups []funcGroup
+		for i := 0; i < (len(funcs)-1)/groupSize+1; i++ {
+			groups = append(groups, funcGroup{
+				Name:  fmt.Sprintf("inst.funcs%d.cpp", i),
+				Funcs: funcs[groupSize*i : min(groupSize*(i+1), len(funcs))],
+			})
+		}
+		return groups, nil
+	case PartitionPackage:
+		pkgs := map[string][]*wasmFunc{}
+		for i, p := range packageNames(funcs) {
+			pkgs[p] = append(pkgs[p], funcs[i])
+		}
+		fileNames := fileNamesFromPackages(pkgs)
+
+		var groups []funcGroup
+		for p, fs := range pkgs {
+			base := "inst." + fileNames[p]
+
+			n := (len(fs)-1)/groupSize + 1
+			if n == 1 {
+				groups = append(groups, funcGroup{
+					Name:  base + ".cpp",
+					Funcs: fs,
+				})
+				continue
+			}
+
+			for i, b := range balanceFuncs(fs, n) {
+				groups = append(groups, funcGroup{
+					Name:  fmt.Sprintf("%s.%d.cpp", base, i),
+					Funcs: b,
+				})
+			}
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			return groups[i].Name < groups[j].Name
+		})
+		return groups, nil
+	default:
+		return nil, fmt.Errorf("gowasm2cpp: unknown partition: %q", partition)
+	}
+}
+
+// balanceFuncs splits fs into n buckets whose total code sizes are balanced.
+//
+// The largest function goes to the bucket of the smallest total size first. Among the buckets of the same size, the
+// bucket of the fewest functions is used. The order of the functions in a bucket is kept.
+func balanceFuncs(fs []*wasmFunc, n int) [][]*wasmFunc {
+	sorted := make([]*wasmFunc, len(fs))
+	copy(sorted, fs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].codeSize() > sorted[j].codeSize()
+	})
+
+	bucketOf := map[*wasmFunc]int{}
+	sizes := make([]int, n)
+	counts := make([]int, n)
+	for _, f := range sorted {
+		var b int
+		for i := range sizes {
+			if sizes[i] < sizes[b] || (sizes[i] == sizes[b] && counts[i] < counts[b]) {
+				b = i
+			}
+		}
+		bucketOf[f] = b
+		sizes[b] += f.codeSize()
+		counts[b]++
+	}
+
+	buckets := make([][]*wasmFunc, n)
+	for _, f := range fs {
+		b := bucketOf[f]
+		buckets[b] = append(buckets[b], f)
+	}
+	return buckets
+}
+
+// codeSize returns the size of the Wasm code of f in bytes.
+func (f *wasmFunc) codeSize() int {
+	if f.Wasm.Body == nil {
+		return 0
+	}
+	return len(f.Wasm.Body.Code)
+}
+
+// mangledDomainRe matches a mangled function name whose package path starts with a domain, e.g.,
+// github.com_foo_bar.F for github.com/foo/bar.F.
+var mangledDomainRe = regexp.MustCompile(`^[0-9A-Za-z_]+\.(?:[0-9a-z]+\.)*[a-z]+_[0-9A-Za-z_]*\.`)
+
+// packageNames returns the Go package paths of the functions.
+//
+// Newer Go linkers replace the characters other than [0-9A-Za-z_.] in the names with '_', e.g.,
+// github.com_foo_bar.__T_.M for github.com/foo/bar.(*T).M. Then, the first dot of a name might be in the domain of the
+// package path. A name like github.com_foo_bar.F is regarded as in the package github.com_foo_bar unless github is the
+// package of another function, as a name like runtime.call_x.func1 has the same form.
+func packageNames(funcs []*wasmFunc) []string {
+	names := make([]string, len(funcs))
+	simple := map[string]struct{}{}
+	for i, f := range funcs {
+		names[i] = packageName(f.Wasm.Name)
+		if !mangledDomainRe.MatchString(f.Wasm.Name) {
+			simple[names[i]] = struct{}{}
+		}
+	}
+	for i, f := range funcs {
+		m := mangledDomainRe.FindString(f.Wasm.Name)
+		if m == "" || strings.Contains(f.Wasm.Name, "/") {
+			continue
+		}
+		if _, ok := simple[names[i]]; ok {
+			continue
+		}
+		names[i] = m[:len(m)-1]
+	}
+	return names
+}
+
+// packageName returns the Go package path of the given function name.
+// packageName returns an empty string if the name doesn't have a package path.
+//
+// packageName doesn't consider mangled names. See packageNames.
+func packageName(name string) string {
+	// Type parameters and receivers might include other package paths.
+	if i := strings.IndexAny(name, "[("); i >= 0 {
+		name = name[:i]
+	}
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return name[:slash+1+dot]
+}
+
+// fileNamesFromPackages returns the file names for the packages.
+//
+// Different packages can have the same sanitized name, e.g., a/b and a_b. The hashes of the package paths are added to
+// such names. The names are compared case-insensitively for case-insensitive file systems.
+func fileNamesFromPackages(pkgs map[string][]*wasmFunc) map[string]string {
+	names := map[string]string{}
+	count := map[string]int{}
+	for p := range pkgs {
+		n := fileNameFromPackage(p)
+		names[p] = n
+		count[strings.ToLower(n)]++
+	}
+	for p, n := range names {
+		if count[strings.ToLower(n)] == 1 {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(p))
+		names[p] = fmt.Sprintf("%s.%08x", n, h.Sum32())
+	}
+	return names
+}
+
+func fileNameFromPackage(pkg string) string {
+	if pkg == "" {
+		return "nopkg"
+	}
+
+	var name strings.Builder
+	for _, r := range pkg {
+		switch {
+		case '0' <= r && r <= '9', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', r == '.', r == '-':
+			name.WriteRune(r)
+		default:
+			name.WriteRune('_')
+		}
+	}
+
+	// Avoid conflicts with the other inst.*.cpp files.
+	switch n := name.String(); n {
+	case "exports", "init", "nopkg":
+		return n + "_"
+	default:
+		return n
+	}
+}
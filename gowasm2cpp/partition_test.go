@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+func TestPackageName(t *testing.T) {
+	cases := []struct {
+		In  string
+		Out string
+	}{
+		{In: "runtime.mallocgc", Out: "runtime"},
+		{In: "fmt.(*pp).printArg", Out: "fmt"},
+		{In: "internal/bytealg.IndexByteString", Out: "internal/bytealg"},
+		{In: "github.com/hajimehoshi/go-inovation/ino.(*Game).Update", Out: "github.com/hajimehoshi/go-inovation/ino"},
+		{In: "gopkg.in/yaml%2ev2.Marshal", Out: "gopkg.in/yaml%2ev2"},
+		{In: "sort.Slice[[]github.com/foo/bar.T]", Out: "sort"},
+		{In: "memeqbody", Out: ""},
+		{In: "_rt0_wasm_js", Out: ""},
+	}
+	for _, c := range cases {
+		if got, want := packageName(c.In), c.Out; got != want {
+			t.Errorf("packageName(%q): got: %q, want: %q", c.In, got, want)
+		}
+	}
+}
+
+func TestPackageNamesMangled(t *testing.T) {
+	// The names are from the name section of a Wasm binary built by Go 1.27.
+	cases := []struct {
+		In  string
+		Out string
+	}{
+		{In: "runtime.__mheap_.alloc", Out: "runtime"},
+		{In: "runtime.mapaccess2_fast32", Out: "runtime"},
+		{In: "runtime.sync_runtime_Semacquire.func1", Out: "runtime"},
+		{In: "internal_strconv.shortFloat_go.shape.float32_", Out: "internal_strconv"},
+		{In: "internal_runtime_maps.__Iter_.grownKeyElem", Out: "internal_runtime_maps"},
+		{In: "syscall_js.valueCall", Out: "syscall_js"},
+		{In: "type_.eq.M17K7M20K4M8", Out: "type_"},
+		{In: "github.com_hajimehoshi_go_inovation_ino.__Game_.Update", Out: "github.com_hajimehoshi_go_inovation_ino"},
+		{In: "github.com_hajimehoshi_ebiten_v2.NewImage.func1", Out: "github.com_hajimehoshi_ebiten_v2"},
+		{In: "gopkg.in_yaml_2ev2.Marshal", Out: "gopkg.in_yaml_2ev2"},
+		{In: "go.uber.org_zap.New", Out: "go.uber.org_zap"},
+		{In: "memeqbody", Out: ""},
+	}
+	var fs []*wasmFunc
+	for _, c := range cases {
+		f := &wasmFunc{}
+		f.Wasm.Name = c.In
+		fs = append(fs, f)
+	}
+	for i, got := range packageNames(fs) {
+		if want := cases[i].Out; got != want {
+			t.Errorf("packageNames: %q: got: %q, want: %q", cases[i].In, got, want)
+		}
+	}
+}
+
+func TestPartitionFuncsByPackage(t *testing.T) {
+	var fs []*wasmFunc
+	for _, n := range []string{"runtime.a", "runtime.b", "fmt.a", "main.main", "memchr"} {
+		f := &wasmFunc{}
+		f.Wasm.Name = n
+		fs = append(fs, f)
+	}
+
+	groups, err := partitionFuncs(fs, PartitionPackage, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	total := 0
+	for _, g := range groups {
+		names = append(names, g.Name)
+		total += len(g.Funcs)
+	}
+	if got, want := total, len(fs); got != want {
+		t.Errorf("got: %d, want: %d", got, want)
+	}
+	for _, n := range []string{"inst.fmt.cpp", "inst.main.cpp", "inst.nopkg.cpp"} {
+		found := false
+		for _, name := range names {
+			if name == n {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s not found in %v", n, names)
+		}
+	}
+}
+
+func TestPartitionFuncsByPackageCollision(t *testing.T) {
+	var fs []*wasmFunc
+	for _, n := range []string{"a/b.F", "a_b.F", "A_B.F", "c.F"} {
+		f := &wasmFunc{}
+		f.Wasm.Name = n
+		fs = append(fs, f)
+	}
+
+	groups, err := partitionFuncs(fs, PartitionPackage, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(groups), len(fs); got != want {
+		t.Fatalf("len(groups): got: %d, want: %d", got, want)
+	}
+	seen := map[string]struct{}{}
+	for _, g := range groups {
+		n := strings.ToLower(g.Name)
+		if _, ok := seen[n]; ok {
+			t.Errorf("duplicated file name: %s", g.Name)
+		}
+		seen[n] = struct{}{}
+		if len(g.Funcs) != 1 {
+			t.Errorf("%s: got: %d functions, want: 1", g.Name, len(g.Funcs))
+		}
+	}
+	if _, ok := seen["inst.c.cpp"]; !ok {
+		t.Errorf("inst.c.cpp must not have a hash suffix: %v", groups)
+	}
+}
+
+func TestPartitionFuncsByPackageBalanced(t *testing.T) {
+	// The code sizes are 1, 2, ..., 40 bytes.
+	var fs []*wasmFunc
+	for i := 0; i < 40; i++ {
+		f := &wasmFunc{}
+		f.Wasm.Name = fmt.Sprintf("runtime.f%d", i)
+		f.Wasm.Body = &wasm.FunctionBody{
+			Code: make([]byte, i+1),
+		}
+		fs = append(fs, f)
+	}
+
+	groups, err := partitionFuncs(fs, PartitionPackage, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(groups), 5; got != want {
+		t.Fatalf("len(groups): got: %d, want: %d", got, want)
+	}
+	var sizes []int
+	for i, g := range groups {
+		if got, want := g.Name, fmt.Sprintf("inst.runtime.%d.cpp", i); got != want {
+			t.Errorf("groups[%d].Name: got: %s, want: %s", i, got, want)
+		}
+		size := 0
+		for _, f := range g.Funcs {
+			size += f.codeSize()
+		}
+		sizes = append(sizes, size)
+	}
+	// The total is 820 bytes. Each bucket has 164 bytes.
+	for i, s := range sizes {
+		if got, want := s, 164; got != want {
+			t.Errorf("the size of bucket %d: got: %d, want: %d", i, got, want)
+		}
+	}
+}
+
+func TestPartitionFuncsByPackageSameSize(t *testing.T) {
+	var fs []*wasmFunc
+	for i := 0; i < 10; i++ {
+		f := &wasmFunc{}
+		f.Wasm.Name = fmt.Sprintf("runtime.f%d", i)
+		fs = append(fs, f)
+	}
+
+	groups, err := partitionFuncs(fs, PartitionPackage, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, g := range groups {
+		got = append(got, len(g.Funcs))
+	}
+	if want := []int{4, 3, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the numbers of the functions: got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// callees returns the indices of the functions called directly from f.
+func (f *wasmFunc) callees() ([]uint32, error) {
+	if f.Wasm.Body == nil {
+		return nil, nil
+	}
+	code, err := f.disassemble()
+	if err != nil {
+		return nil, err
+	}
+	var r []uint32
+	for _, instr := range code {
+		if instr.Op == wasm.OpCall {
+			r = append(r, instr.Immediates[0].(uint32))
+		}
+	}
+	return r, nil
+}
+
+var cppCallRe = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+
+// removeUnreachableFuncs removes the defined functions that are never called from m.funcs.
+//
+// The roots are the exported functions, the functions in the tables, and the functions called from the C++ bodies
+// of the imported functions and the overridden functions. For example, imported functions call back into Go via
+// exported functions like resume.
+//
+// removeUnreachableFuncs returns the number of the removed functions and the total size of their Wasm code.
+func (m *module) removeUnreachableFuncs() (int, int) {
+	numImports := len(m.importFuncs)
+
+	calls := make([][]uint32, len(m.funcs))
+	errs := make([]error, len(m.funcs))
+	var wg sync.WaitGroup
+	for i, f := range m.funcs {
+		i, f := i, f
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls[i], errs[i] = f.callees()
+		}()
+	}
+	wg.Wait()
+
+	// A function that cannot be disassembled might call any function. Keep all the functions so that no live
+	// function is removed by mistake. The error is reported when the function is converted.
+	for _, err := range errs {
+		if err != nil {
+			return 0, 0
+		}
+	}
+
+	// C++ bodies call functions by the identifiers of the functions or the exports.
+	idents := map[string]uint32{}
+	for _, f := range m.funcs {
+		idents[f.Identifier()] = uint32(f.Index)
+	}
+	for _, e := range m.exports {
+		idents[e.Name] = uint32(e.Index)
+	}
+
+	reachable := map[uint32]struct{}{}
+	var queue []uint32
+	visit := func(index uint32) {
+		if _, ok := reachable[index]; ok {
+			return
+		}
+		reachable[index] = struct{}{}
+		queue = append(queue, index)
+	}
+	visitCpp := func(body string) {
+		for _, match := range cppCallRe.FindAllStringSubmatch(body, -1) {
+			if index, ok := idents[match[1]]; ok {
+				visit(index)
+			}
+		}
+	}
+
+	for _, e := range m.exports {
+		visit(uint32(e.Index))
+	}
+	for _, t := range m.tables {
+		for _, index := range t {
+			visit(index)
+		}
+	}
+	for _, f := range m.importFuncs {
+		visitCpp(f.BodyStr)
+	}
+	for len(queue) > 0 {
+		index := queue[0]
+		queue = queue[1:]
+		if int(index) < numImports || int(index) >= numImports+len(m.funcs) {
+			continue
+		}
+		f := m.funcs[int(index)-numImports]
+		if f.BodyStr != "" {
+			visitCpp(f.BodyStr)
+			continue
+		}
+		for _, callee := range calls[int(index)-numImports] {
+			visit(callee)
+		}
+	}
+
+	var funcs []*wasmFunc
+	var num, size int
+	for i, f := range m.funcs {
+		if _, ok := reachable[uint32(f.Index)]; ok {
+			funcs = append(funcs, f)
+			continue
+		}
+		num++
+		size += len(m.mod.Code[i].Code)
+	}
+	m.funcs = funcs
+	return num, size
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// reachModule is a Wasm module that has these functions:
+//
+//   - main.run, exported as run, calls main.used.
+//   - main.used.
+//   - main.dead, not referred, calls main.used.
+//   - main.indirect, in the table.
+var reachModule = Module(
+	Section(SectionType, Vec(FuncType(nil, nil))...),
+	Section(SectionImport, Vec()...),
+	Section(SectionFunction, Vec(U32(0), U32(0), U32(0), U32(0))...),
+	Section(SectionTable, Vec(Table(1))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+	Section(SectionExport, Vec(Export("run", KindFunction, 0), Export("mem", KindMemory, 0))...),
+	Section(SectionElement, Vec(Element(0, 3))...),
+	Section(SectionCode, Vec(
+		// call 1
+		Body(0x10, 0x01),
+		Body(),
+		// call 1
+		Body(0x10, 0x01),
+		Body(),
+	)...),
+	Names("main.run", "main.used", "main.dead", "main.indirect"),
+)
+
+func TestRemoveUnreachableFuncs(t *testing.T) {
+	m, err := newModule(reachModule, DefaultRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadSize := len(m.mod.Code[2].Code)
+	num, size := m.removeUnreachableFuncs()
+	if got, want := num, 1; got != want {
+		t.Errorf("removed functions: got: %d, want: %d", got, want)
+	}
+	if got, want := size, deadSize; got != want {
+		t.Errorf("removed code size: got: %d, want: %d", got, want)
+	}
+
+	var names []string
+	for _, f := range m.funcs {
+		names = append(names, f.Wasm.Name)
+	}
+	want := []string{"main.run", "main.used", "main.indirect"}
+	if len(names) != len(want) {
+		t.Fatalf("functions: got: %v, want: %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("functions: got: %v, want: %v", names, want)
+			break
+		}
+	}
+	if got, want := m.numFuncs(), 4; got != want {
+		t.Errorf("numFuncs(): got: %d, want: %d", got, want)
+	}
+}
+
+func TestRemoveUnreachableFuncsUnknownCallees(t *testing.T) {
+	// main.bad in inspectModule cannot be disassembled. As main.bad might call any function, all the functions
+	// including main.dead are kept.
+	m, err := newModule(inspectModule, DefaultRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	num, size := m.removeUnreachableFuncs()
+	if num != 0 || size != 0 {
+		t.Errorf("removed functions: got: %d (%d bytes), want: 0 (0 bytes)", num, size)
+	}
+	if got, want := len(m.funcs), 3; got != want {
+		t.Errorf("len(m.funcs): got: %d, want: %d", got, want)
+	}
+}
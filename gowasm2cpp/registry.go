@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+// Registry is a set of C++ function bodies for Wasm functions.
+//
+// A body for an imported function implements the import. For the runtime and syscall/js imports, a body replaces the
+// built-in one for the Go version. A body for a defined function replaces the original
+// implementation, e.g., with hand-written native code for a hot Go function.
+//
+// In a body, the arguments are named local0_, local1_, and so on. Imported functions can access the Go instance
+// by go_, and defined functions can access the memory by mem_.
+type Registry struct {
+	bodies map[string]*registeredBody
+}
+
+type registeredBody struct {
+	// sig is the canonical signature. If sig is empty, the signature is not checked.
+	sig  string
+	body string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		bodies: map[string]*registeredBody{},
+	}
+}
+
+// DefaultRegistry returns a new registry that has the built-in function bodies.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	for name, body := range specialFunctionBodies {
+		r.bodies[name] = &registeredBody{body: body}
+	}
+	for i := range wasiFuncs {
+		if err := wasiFuncs[i].register(r); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register registers the C++ function body for the Wasm function name.
+// If a body is already registered for the name, the body is replaced.
+//
+// signature is the Wasm signature of the function like "(i32, i32) -> i64" or "(i32)".
+// Generating C++ files fails when the signature doesn't match with the Wasm function type.
+func (r *Registry) Register(name string, signature string, body string) error {
+	params, results, err := parseSignature(signature)
+	if err != nil {
+		return err
+	}
+	r.bodies[name] = &registeredBody{
+		sig:  formatSignature(params, results),
+		body: body,
+	}
+	return nil
+}
+
+// LoadDir registers the C++ snippets in the .cpp files in the directory dir.
+//
+// A snippet starts with a name line and a signature line, followed by the function body:
+//
+//	// name: runtime.memhash
+//	// signature: (i32) -> i32
+//	  ...
+//
+// A file can have multiple snippets.
+func (r *Registry) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.cpp"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := r.loadSnippets(path, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) loadSnippets(path string, content []byte) error {
+	const (
+		namePrefix = "// name:"
+		sigPrefix  = "// signature:"
+	)
+
+	var name, sig string
+	var lines []string
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		if sig == "" {
+			return fmt.Errorf("gowasm2cpp: %s: no signature for %q", path, name)
+		}
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if err := r.Register(name, sig, strings.Join(lines, "\n")); err != nil {
+			return fmt.Errorf("gowasm2cpp: %s: %v", path, err)
+		}
+		name, sig, lines = "", "", nil
+		return nil
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l, namePrefix):
+			if err := flush(); err != nil {
+				return err
+			}
+			name = strings.TrimSpace(l[len(namePrefix):])
+		case name != "" && sig == "" && strings.HasPrefix(l, sigPrefix):
+			sig = strings.TrimSpace(l[len(sigPrefix):])
+		case name != "":
+			lines = append(lines, l)
+		case strings.TrimSpace(l) != "" && !strings.HasPrefix(l, "//"):
+			return fmt.Errorf("gowasm2cpp: %s: code before a name line: %q", path, l)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// clone returns a copy of r.
+func (r *Registry) clone() *Registry {
+	r2 := NewRegistry()
+	for name, b := range r.bodies {
+		r2.bodies[name] = b
+	}
+	return r2
+}
+
+// body returns the C++ body for the Wasm function name with the signature sig.
+// body returns an error if the registered signature doesn't match with sig.
+func (r *Registry) body(name string, sig *wasm.FunctionSig) (string, bool, error) {
+	b, ok := r.bodies[name]
+	if !ok {
+		return "", false, nil
+	}
+	if b.sig != "" {
+		if s := formatSignature(sig.ParamTypes, sig.ReturnTypes); s != b.sig {
+			return "", false, fmt.Errorf("gowasm2cpp: signature mismatch for %q: registered: %s, Wasm: %s", name, b.sig, s)
+		}
+	}
+	return b.body, true, nil
+}
+
+var valueTypeNames = map[wasm.ValueType]string{
+	wasm.ValueTypeI32: "i32",
+	wasm.ValueTypeI64: "i64",
+	wasm.ValueTypeF32: "f32",
+	wasm.ValueTypeF64: "f64",
+}
+
+// formatSignature returns the canonical form of the signature like "(i32, i32) -> i64".
+func formatSignature(params, results []wasm.ValueType) string {
+	str := func(ts []wasm.ValueType) string {
+		var names []string
+		for _, t := range ts {
+			names = append(names, valueTypeNames[t])
+		}
+		return strings.Join(names, ", ")
+	}
+
+	s := "(" + str(params) + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		s += " -> " + str(results)
+	default:
+		s += " -> (" + str(results) + ")"
+	}
+	return s
+}
+
+// parseSignature parses a signature like "(i32, i32) -> i64".
+func parseSignature(sig string) ([]wasm.ValueType, []wasm.ValueType, error) {
+	parseTypes := func(str string) ([]wasm.ValueType, error) {
+		str = strings.TrimSpace(str)
+		if !strings.HasPrefix(str, "(") || !strings.HasSuffix(str, ")") {
+			return nil, fmt.Errorf("gowasm2cpp: invalid signature: %q", sig)
+		}
+		str = strings.TrimSpace(str[1 : len(str)-1])
+		if str == "" {
+			return nil, nil
+		}
+		var ts []wasm.ValueType
+	loop:
+		for _, n := range strings.Split(str, ",") {
+			n = strings.TrimSpace(n)
+			for t, name := range valueTypeNames {
+				if n == name {
+					ts = append(ts, t)
+					continue loop
+				}
+			}
+			return nil, fmt.Errorf("gowasm2cpp: invalid type %q in signature: %q", n, sig)
+		}
+		return ts, nil
+	}
+
+	ps := sig
+	var rs string
+	if i := strings.Index(sig, "->"); i >= 0 {
+		ps = sig[:i]
+		rs = strings.TrimSpace(sig[i+len("->"):])
+		if !strings.HasPrefix(rs, "(") {
+			rs = "(" + rs + ")"
+		}
+	}
+	params, err := parseTypes(ps)
+	if err != nil {
+		return nil, nil, err
+	}
+	var results []wasm.ValueType
+	if rs != "" {
+		results, err = parseTypes(rs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return params, results, nil
+}
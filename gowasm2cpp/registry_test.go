@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestParseSignature(t *testing.T) {
+	cases := []struct {
+		In   string
+		Want string
+		Err  bool
+	}{
+		{In: "()", Want: "()"},
+		{In: "(i32)", Want: "(i32)"},
+		{In: "( i32,i64 ) -> f64", Want: "(i32, i64) -> f64"},
+		{In: "(f32) -> (i32)", Want: "(f32) -> i32"},
+		{In: "() -> (i32, i64)", Want: "() -> (i32, i64)"},
+		{In: "i32", Err: true},
+		{In: "(i8)", Err: true},
+		{In: "(i32) -> (", Err: true},
+	}
+	for _, c := range cases {
+		params, results, err := parseSignature(c.In)
+		if c.Err {
+			if err == nil {
+				t.Errorf("parseSignature(%q) must return an error", c.In)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSignature(%q): %v", c.In, err)
+			continue
+		}
+		if got := formatSignature(params, results); got != c.Want {
+			t.Errorf("parseSignature(%q): got: %q, want: %q", c.In, got, c.Want)
+		}
+	}
+}
+
+func TestRegistryLoadSnippets(t *testing.T) {
+	r := NewRegistry()
+	if err := r.loadSnippets("foo.cpp", []byte(`// Copyright
+
+// name: main.f
+// signature: ()
+  foo();
+
+// name: main.g
+// signature: (i32) -> i32
+  return local0_;
+`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.bodies["main.f"].body, "  foo();"; got != want {
+		t.Errorf("main.f: got: %q, want: %q", got, want)
+	}
+	if got, want := r.bodies["main.g"].sig, "(i32) -> i32"; got != want {
+		t.Errorf("main.g: got: %q, want: %q", got, want)
+	}
+
+	if err := r.loadSnippets("bar.cpp", []byte("// name: main.h\n  return;\n")); err == nil {
+		t.Errorf("a snippet without a signature must be an error")
+	}
+}
+
+func TestRegistryOverride(t *testing.T) {
+	r := DefaultRegistry()
+	if err := r.Register("main.f", "()", "  native();"); err != nil {
+		t.Fatal(err)
+	}
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Module:  minimalModule,
+		Output:  out,
+		Options: Options{Registry: r},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out["inst.funcs0.cpp"], []byte("  native();")) {
+		t.Errorf("main.f is not overridden")
+	}
+
+	if err := r.Register("main.f", "(i32)", "  native();"); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Module:  minimalModule,
+		Output:  MapOutput{},
+		Options: Options{Registry: r},
+	}); err == nil {
+		t.Errorf("a mismatched signature must be an error")
+	}
+}
+
+func TestOptionsOverrides(t *testing.T) {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Module: minimalModule,
+		Output: out,
+		Options: Options{
+			Overrides: map[string]Override{
+				"main.f": {Signature: "()", Body: "  native();"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out["inst.funcs0.cpp"], []byte("  native();")) {
+		t.Errorf("main.f is not overridden")
+	}
+
+	for _, ov := range []Override{
+		{Signature: "(i32)", Body: "  native();"},
+		{Body: "  native();"},
+	} {
+		if err := GenerateWithConfig(context.Background(), Config{
+			Module: minimalModule,
+			Output: MapOutput{},
+			Options: Options{
+				Overrides: map[string]Override{
+					"main.f": ov,
+				},
+			},
+		}); err == nil {
+			t.Errorf("signature %q: a mismatched signature must be an error", ov.Signature)
+		}
+	}
+
+	if err := GenerateWithConfig(context.Background(), Config{
+		Module: minimalModule,
+		Output: MapOutput{},
+		Options: Options{
+			Overrides: map[string]Override{
+				"main.g": {Signature: "()", Body: "  native();"},
+			},
+		},
+	}); err == nil {
+		t.Errorf("overriding a missing function must be an error")
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Output is a destination of the generated files.
+//
+// WriteFile is not called concurrently.
+type Output interface {
+	// WriteFile writes content to the file name. name is a slash-separated relative path.
+	WriteFile(name string, content []byte) error
+}
+
+// DirOutput is an Output to write files to the directory.
+//
+// If a file already exists with the same content, the file is not touched so that its modification time is kept.
+// This enables build systems to skip recompiling unchanged files.
+// Generated files that are no longer generated are removed.
+type DirOutput string
+
+// WriteFile implements Output.
+func (d DirOutput) WriteFile(name string, content []byte) error {
+	path := filepath.Join(string(d), filepath.FromSlash(name))
+	old, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(old, content) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// removeStaleFiles removes the generated files that match pattern and are not in names.
+// Files without the header of generated code are never removed.
+func (d DirOutput) removeStaleFiles(pattern string, names map[string]struct{}) error {
+	paths, err := filepath.Glob(filepath.Join(string(d), pattern))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		if _, ok := names[filepath.Base(p)]; ok {
+			continue
+		}
+		generated, err := isGenerated(p)
+		if err != nil {
+			return err
+		}
+		if !generated {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readManifest returns the manifest generated previously in the directory, or nil if there is no manifest.
+func (d DirOutput) readManifest() (*Manifest, error) {
+	path := filepath.Join(string(d), ManifestFileName)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("gowasm2cpp: %s: %v", path, err)
+	}
+	return &m, nil
+}
+
+// isGenerated reports whether the file at path is generated by go2cpp.
+func isGenerated(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, len(generatedHeader))
+	if _, err := io.ReadFull(f, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(buf) == generatedHeader, nil
+}
+
+// MapOutput is an Output to keep files in memory. The keys are the file names.
+type MapOutput map[string][]byte
+
+// WriteFile implements Output.
+func (m MapOutput) WriteFile(name string, content []byte) error {
+	m[name] = append([]byte(nil), content...)
+	return nil
+}
+
+// archiveModTime is the modification time of the files in archives.
+// A fixed time is used so that archives are reproducible.
+var archiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ZipOutput is an Output to write files to a zip archive.
+type ZipOutput struct {
+	w *zip.Writer
+}
+
+// NewZipOutput returns a new ZipOutput writing a zip archive to w.
+// The caller must call Close after the generation.
+func NewZipOutput(w io.Writer) *ZipOutput {
+	return &ZipOutput{
+		w: zip.NewWriter(w),
+	}
+}
+
+// WriteFile implements Output.
+func (z *ZipOutput) WriteFile(name string, content []byte) error {
+	f, err := z.w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: archiveModTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+// Close finishes writing the zip archive. Close does not close the underlying writer.
+func (z *ZipOutput) Close() error {
+	return z.w.Close()
+}
+
+// TarOutput is an Output to write files to a tar archive.
+type TarOutput struct {
+	w *tar.Writer
+}
+
+// NewTarOutput returns a new TarOutput writing a tar archive to w.
+// The caller must call Close after the generation.
+func NewTarOutput(w io.Writer) *TarOutput {
+	return &TarOutput{
+		w: tar.NewWriter(w),
+	}
+}
+
+// WriteFile implements Output.
+func (t *TarOutput) WriteFile(name string, content []byte) error {
+	if err := t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  archiveModTime,
+	}); err != nil {
+		return err
+	}
+	_, err := t.w.Write(content)
+	return err
+}
+
+// Close finishes writing the tar archive. Close does not close the underlying writer.
+func (t *TarOutput) Close() error {
+	return t.w.Close()
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirOutputRemoveStaleFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowasm2cpp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	generate := func(unity int) {
+		if err := GenerateWithConfig(context.Background(), Config{
+			Namespace: "foo",
+			Module:    minimalModule,
+			Output:    DirOutput(dir),
+			Options: Options{
+				Unity: unity,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	generate(0)
+	if !exists("inst.funcs0.cpp") {
+		t.Fatalf("inst.funcs0.cpp must exist")
+	}
+
+	// A stale file generated by an older run, and a hand-written file without the header of generated code.
+	if err := ioutil.WriteFile(filepath.Join(dir, "inst.funcs7.cpp"), []byte(generatedHeader+"// stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	handWritten := []byte("// hand-written\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.cpp"), handWritten, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unity build changes the set of the C++ files.
+	generate(1)
+	if !exists("go2cpp_all.cpp") {
+		t.Errorf("go2cpp_all.cpp must exist")
+	}
+	for _, name := range []string{"inst.funcs0.cpp", "inst.funcs7.cpp"} {
+		if exists(name) {
+			t.Errorf("%s must be removed", name)
+		}
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dir, "main.cpp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, handWritten) {
+		t.Errorf("main.cpp: got: %q, want: %q", got, handWritten)
+	}
+}
+
+func TestDirOutputSplitRuntimeInSameDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowasm2cpp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	generate := func(options Options) error {
+		return GenerateWithConfig(context.Background(), Config{
+			Namespace: "foo",
+			Module:    minimalModule,
+			Output:    DirOutput(dir),
+			Options:   options,
+		})
+	}
+
+	// Generating the same part again is fine.
+	if err := generate(Options{RuntimeOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(Options{RuntimeOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The program would remove the runtime files as stale.
+	if err := generate(Options{ProgramOnly: true}); err == nil {
+		t.Errorf("GenerateWithConfig with ProgramOnly must fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "js.h")); err != nil {
+		t.Errorf("js.h must be kept: %v", err)
+	}
+
+	// Generating both the runtime and the program replaces the runtime.
+	if err := generate(Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(Options{ProgramOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(Options{RuntimeOnly: true}); err == nil {
+		t.Errorf("GenerateWithConfig with RuntimeOnly must fail")
+	}
+}
+
+func TestDirOutputKeepsUnchangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gowasm2cpp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := DirOutput(dir)
+	if err := out.WriteFile("sub/a.h", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sub", "a.h")
+	old := archiveModTime
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := out.WriteFile("sub/a.h", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) {
+		t.Errorf("the modification time of an unchanged file: got: %v, want: %v", fi.ModTime(), old)
+	}
+}
+
+// generateToMap generates C++ files from minimalModule to a MapOutput.
+func generateToMap(t *testing.T) MapOutput {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    minimalModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// checkArchivedFiles checks that the files read from an archive are the same as want.
+func checkArchivedFiles(t *testing.T, got, want MapOutput) {
+	if len(got) != len(want) {
+		t.Errorf("the number of files: got: %d, want: %d", len(got), len(want))
+	}
+	for name, content := range want {
+		c, ok := got[name]
+		if !ok {
+			t.Errorf("%s is not in the archive", name)
+			continue
+		}
+		if !bytes.Equal(c, content) {
+			t.Errorf("%s: the content doesn't match", name)
+		}
+	}
+}
+
+func TestTarOutput(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewTarOutput(&buf)
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    minimalModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := MapOutput{}
+	r := tar.NewReader(&buf)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !h.ModTime.Equal(archiveModTime) {
+			t.Errorf("%s: ModTime: got: %v, want: %v", h.Name, h.ModTime, archiveModTime)
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[h.Name] = content
+	}
+	checkArchivedFiles(t, got, generateToMap(t))
+}
+
+func TestZipOutput(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewZipOutput(&buf)
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    minimalModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := MapOutput{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = content
+	}
+	checkArchivedFiles(t, got, generateToMap(t))
+}
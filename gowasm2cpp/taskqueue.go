@@ -3,47 +3,29 @@
 package gowasm2cpp
 
 import (
-	"os"
-	"path/filepath"
 	"text/template"
 )
 
-func writeTaskQueue(dir string, incpath string, namespace string) error {
-	{
-		f, err := os.Create(filepath.Join(dir, "taskqueue.h"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := taskqueueHTmpl.Execute(f, struct {
-			IncludeGuard string
-			IncludePath  string
-			Namespace    string
-		}{
-			IncludeGuard: includeGuard(namespace) + "_TASKQUEUE_H",
-			IncludePath:  incpath,
-			Namespace:    namespace,
-		}); err != nil {
-			return err
-		}
+func writeTaskQueue(out *output, incpath string, namespace string) error {
+	if err := out.writeFile("taskqueue.h", taskqueueHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+	}{
+		IncludeGuard: includeGuard(namespace) + "_TASKQUEUE_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+	}); err != nil {
+		return err
 	}
-	{
-		f, err := os.Create(filepath.Join(dir, "taskqueue.cpp"))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		if err := taskqueueCppTmpl.Execute(f, struct {
-			IncludePath string
-			Namespace   string
-		}{
-			IncludePath: incpath,
-			Namespace:   namespace,
-		}); err != nil {
-			return err
-		}
+	if err := out.writeFile("taskqueue.cpp", taskqueueCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+	}); err != nil {
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const generatedHeader = "// Code generated by go2cpp. DO NOT EDIT.\n"
+
+// unitySource is a C++ source file to be amalgamated.
+type unitySource struct {
+	name    string
+	content []byte
+}
+
+// unityFileName returns the name of the i-th amalgamated file out of n.
+func unityFileName(i, n int) string {
+	if n == 1 {
+		return "go2cpp_all.cpp"
+	}
+	return fmt.Sprintf("go2cpp_all%d.cpp", i)
+}
+
+// amalgamate merges the C++ sources into n translation units.
+// The sources are distributed so that the sizes of the translation units are balanced.
+func amalgamate(srcs []unitySource, n int) map[string][]byte {
+	sort.Slice(srcs, func(i, j int) bool {
+		return srcs[i].name < srcs[j].name
+	})
+
+	units := make([][]unitySource, n)
+	sizes := make([]int, n)
+	for _, src := range srcs {
+		min := 0
+		for i := range sizes {
+			if sizes[i] < sizes[min] {
+				min = i
+			}
+		}
+		units[min] = append(units[min], src)
+		sizes[min] += len(src.content)
+	}
+
+	r := map[string][]byte{}
+	for i, u := range units {
+		if len(u) == 0 {
+			continue
+		}
+		r[unityFileName(i, n)] = amalgamateUnit(u)
+	}
+	return r
+}
+
+// amalgamateUnit concatenates the C++ sources into one translation unit.
+//
+// Each source has its own helpers like error() in an anonymous namespace. In one translation unit, the anonymous
+// namespaces of all the sources are the same namespace, and then the helpers with the same name conflict. Such
+// helpers are renamed by macros in each source.
+func amalgamateUnit(srcs []unitySource) []byte {
+	defined := map[string]int{}
+	helpers := make([][]string, len(srcs))
+	for i, src := range srcs {
+		helpers[i] = anonymousNames(src.content)
+		for _, h := range helpers[i] {
+			defined[h]++
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(generatedHeader)
+	for i, src := range srcs {
+		var renamed []string
+		for _, h := range helpers[i] {
+			if defined[h] > 1 {
+				renamed = append(renamed, h)
+			}
+		}
+
+		fmt.Fprintf(&buf, "\n// %s\n", src.name)
+		prefix := identifierFromString(strings.TrimSuffix(src.name, ".cpp"))
+		for _, h := range renamed {
+			fmt.Fprintf(&buf, "#define %s %s_%s\n", h, prefix, h)
+		}
+		buf.Write(bytes.TrimPrefix(src.content, []byte(generatedHeader)))
+		for _, h := range renamed {
+			fmt.Fprintf(&buf, "#undef %s\n", h)
+		}
+	}
+	return buf.Bytes()
+}
+
+var (
+	anonymousTypeRe = regexp.MustCompile(`^(?:class|struct|enum class|enum|union)\s+([A-Za-z_]\w*)`)
+	anonymousNameRe = regexp.MustCompile(`([A-Za-z_]\w*)\s*(?:\(|\[|=|;)`)
+)
+
+// anonymousNames returns the sorted names of the functions, types and variables defined at the top level of the
+// anonymous namespaces in the C++ source. The definitions of members with qualified names are not included.
+func anonymousNames(content []byte) []string {
+	names := map[string]struct{}{}
+
+	// depth is the depth of the braces. -1 means that the current line is out of anonymous namespaces.
+	depth := -1
+	for _, l := range strings.Split(string(content), "\n") {
+		if depth == -1 {
+			if strings.TrimSpace(l) == "namespace {" {
+				depth = 0
+			}
+			continue
+		}
+
+		if depth == 0 {
+			if strings.HasPrefix(l, "}") {
+				depth = -1
+				continue
+			}
+			if l != "" && l[0] != ' ' && l[0] != '#' && !strings.HasPrefix(l, "//") {
+				if m := anonymousTypeRe.FindStringSubmatch(l); m != nil {
+					names[m[1]] = struct{}{}
+				} else if m := anonymousNameRe.FindStringSubmatchIndex(l); m != nil {
+					// A qualified name like Helper::Do is a member of a type defined elsewhere, and renaming it
+					// would rename all the members with the same name.
+					if n := l[m[2]:m[3]]; n != "operator" && !strings.HasSuffix(strings.TrimRight(l[:m[2]], " "), "::") {
+						names[n] = struct{}{}
+					}
+				}
+			}
+		}
+		depth += braceDelta(l)
+		if depth < 0 {
+			depth = -1
+		}
+	}
+
+	var r []string
+	for n := range names {
+		r = append(r, n)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// braceDelta returns the number of opening braces minus the number of closing braces in the line.
+// Braces in string or character literals and comments are ignored.
+func braceDelta(line string) int {
+	var d int
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '/':
+			if i+1 < len(line) && line[i+1] == '/' {
+				return d
+			}
+		case '{':
+			d++
+		case '}':
+			d--
+		}
+	}
+	return d
+}
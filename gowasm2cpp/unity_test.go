@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnonymousNames(t *testing.T) {
+	src := `#include "foo.h"
+
+namespace {
+
+const uint8_t table[] = {
+  0x00, 0x01,
+};
+
+const int kSize = 2;
+
+void error(const std::string& msg) {
+  if (msg.empty()) {
+    return;
+  }
+  std::cerr << "}" << msg << std::endl;
+}
+
+class Helper {
+public:
+  void Do();
+};
+
+void Helper::Do() {
+}
+
+Helper::Helper() = default;
+
+std::string Name(const Helper& h) {
+  return "";
+}
+
+}
+
+namespace foo {
+
+void Foo() {
+}
+
+}
+`
+	got := anonymousNames([]byte(src))
+	// Helper::Do is a member definition and must not be renamed.
+	want := []string{"Helper", "Name", "error", "kSize", "table"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("anonymousNames(): got: %v, want: %v", got, want)
+	}
+}
+
+func TestAmalgamateRenamesCollidedHelpers(t *testing.T) {
+	src := func(helper string) []byte {
+		return []byte(generatedHeader + `
+namespace {
+
+void error(const std::string& msg) {
+}
+
+void ` + helper + `() {
+}
+
+}
+`)
+	}
+	units := amalgamate([]unitySource{
+		{name: "b.cpp", content: src("bar")},
+		{name: "a.cpp", content: src("foo")},
+	}, 1)
+	if len(units) != 1 {
+		t.Fatalf("len(units): got: %d, want: 1", len(units))
+	}
+	got := string(units["go2cpp_all.cpp"])
+
+	if n := strings.Count(got, generatedHeader); n != 1 {
+		t.Errorf("the number of the headers: got: %d, want: 1", n)
+	}
+	for _, s := range []string{"#define error a_error\n", "#define error b_error\n"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("%q is not found", s)
+		}
+	}
+	for _, s := range []string{"#define foo", "#define bar"} {
+		if strings.Contains(got, s) {
+			t.Errorf("%q must not be found", s)
+		}
+	}
+	if strings.Index(got, "// a.cpp") > strings.Index(got, "// b.cpp") {
+		t.Errorf("the sources must be sorted by name")
+	}
+}
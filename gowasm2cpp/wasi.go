@@ -0,0 +1,1341 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	// wasiModule is the module of the WASI functions that GOOS=wasip1 programs import.
+	wasiModule = "wasi_snapshot_preview1"
+
+	// wasiStart is the exported function to run a WASI program.
+	wasiStart = "_start"
+)
+
+type wasiFunc struct {
+	Name      string
+	Signature string
+	Method    string
+}
+
+// wasiFuncs is the WASI functions implemented by the Wasi class.
+//
+// See https://github.com/WebAssembly/WASI/blob/main/legacy/preview1/docs.md
+var wasiFuncs = []wasiFunc{
+	{"args_get", "(i32, i32) -> i32", "ArgsGet"},
+	{"args_sizes_get", "(i32, i32) -> i32", "ArgsSizesGet"},
+	{"environ_get", "(i32, i32) -> i32", "EnvironGet"},
+	{"environ_sizes_get", "(i32, i32) -> i32", "EnvironSizesGet"},
+	{"clock_res_get", "(i32, i32) -> i32", "ClockResGet"},
+	{"clock_time_get", "(i32, i64, i32) -> i32", "ClockTimeGet"},
+	{"fd_advise", "(i32, i64, i64, i32) -> i32", "FdAdvise"},
+	{"fd_allocate", "(i32, i64, i64) -> i32", "FdAllocate"},
+	{"fd_close", "(i32) -> i32", "FdClose"},
+	{"fd_datasync", "(i32) -> i32", "FdDatasync"},
+	{"fd_fdstat_get", "(i32, i32) -> i32", "FdFdstatGet"},
+	{"fd_fdstat_set_flags", "(i32, i32) -> i32", "FdFdstatSetFlags"},
+	{"fd_fdstat_set_rights", "(i32, i64, i64) -> i32", "FdFdstatSetRights"},
+	{"fd_filestat_get", "(i32, i32) -> i32", "FdFilestatGet"},
+	{"fd_filestat_set_size", "(i32, i64) -> i32", "FdFilestatSetSize"},
+	{"fd_filestat_set_times", "(i32, i64, i64, i32) -> i32", "FdFilestatSetTimes"},
+	{"fd_pread", "(i32, i32, i32, i64, i32) -> i32", "FdPread"},
+	{"fd_prestat_get", "(i32, i32) -> i32", "FdPrestatGet"},
+	{"fd_prestat_dir_name", "(i32, i32, i32) -> i32", "FdPrestatDirName"},
+	{"fd_pwrite", "(i32, i32, i32, i64, i32) -> i32", "FdPwrite"},
+	{"fd_read", "(i32, i32, i32, i32) -> i32", "FdRead"},
+	{"fd_readdir", "(i32, i32, i32, i64, i32) -> i32", "FdReaddir"},
+	{"fd_renumber", "(i32, i32) -> i32", "FdRenumber"},
+	{"fd_seek", "(i32, i64, i32, i32) -> i32", "FdSeek"},
+	{"fd_sync", "(i32) -> i32", "FdSync"},
+	{"fd_tell", "(i32, i32) -> i32", "FdTell"},
+	{"fd_write", "(i32, i32, i32, i32) -> i32", "FdWrite"},
+	{"path_create_directory", "(i32, i32, i32) -> i32", "PathCreateDirectory"},
+	{"path_filestat_get", "(i32, i32, i32, i32, i32) -> i32", "PathFilestatGet"},
+	{"path_filestat_set_times", "(i32, i32, i32, i32, i64, i64, i32) -> i32", "PathFilestatSetTimes"},
+	{"path_link", "(i32, i32, i32, i32, i32, i32, i32) -> i32", "PathLink"},
+	{"path_open", "(i32, i32, i32, i32, i32, i64, i64, i32, i32) -> i32", "PathOpen"},
+	{"path_readlink", "(i32, i32, i32, i32, i32, i32) -> i32", "PathReadlink"},
+	{"path_remove_directory", "(i32, i32, i32) -> i32", "PathRemoveDirectory"},
+	{"path_rename", "(i32, i32, i32, i32, i32, i32) -> i32", "PathRename"},
+	{"path_symlink", "(i32, i32, i32, i32, i32) -> i32", "PathSymlink"},
+	{"path_unlink_file", "(i32, i32, i32) -> i32", "PathUnlinkFile"},
+	{"poll_oneoff", "(i32, i32, i32, i32) -> i32", "PollOneoff"},
+	{"proc_exit", "(i32)", "ProcExit"},
+	{"proc_raise", "(i32) -> i32", "ProcRaise"},
+	{"random_get", "(i32, i32) -> i32", "RandomGet"},
+	{"sched_yield", "() -> i32", "SchedYield"},
+	{"sock_accept", "(i32, i32, i32) -> i32", "SockAccept"},
+	{"sock_recv", "(i32, i32, i32, i32, i32, i32) -> i32", "SockRecv"},
+	{"sock_send", "(i32, i32, i32, i32, i32) -> i32", "SockSend"},
+	{"sock_shutdown", "(i32, i32) -> i32", "SockShutdown"},
+}
+
+// register registers the body of the imported function that calls the Wasi method.
+func (w *wasiFunc) register(r *Registry) error {
+	params, results, err := parseSignature(w.Signature)
+	if err != nil {
+		return err
+	}
+	var args []string
+	for i := range params {
+		args = append(args, fmt.Sprintf("local%d_", i))
+	}
+	var ret string
+	if len(results) > 0 {
+		ret = "return "
+	}
+	return r.Register(wasiModule+"."+w.Name, w.Signature, fmt.Sprintf("  %sgo_->wasi_.%s(%s);", ret, w.Method, strings.Join(args, ", ")))
+}
+
+// CppDecl returns the declaration of the Wasi method.
+func (w *wasiFunc) CppDecl() (string, error) {
+	params, results, err := parseSignature(w.Signature)
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	for i, t := range params {
+		args = append(args, fmt.Sprintf("%s arg%d", wasmTypeToReturnType(t).Cpp(), i))
+	}
+	ret := "[[noreturn]] void"
+	if len(results) > 0 {
+		ret = wasmTypeToReturnType(results[0]).Cpp()
+	}
+	return fmt.Sprintf("%s %s(%s);", ret, w.Method, strings.Join(args, ", ")), nil
+}
+
+func writeWASI(out *output, incpath string, namespace string, runtime runtimeRef) error {
+	if err := out.writeFile("wasi.h", wasiHTmpl, struct {
+		IncludeGuard string
+		IncludePath  string
+		Namespace    string
+		Runtime      runtimeRef
+		Funcs        []wasiFunc
+	}{
+		IncludeGuard: includeGuard(namespace) + "_WASI_H",
+		IncludePath:  incpath,
+		Namespace:    namespace,
+		Runtime:      runtime,
+		Funcs:        wasiFuncs,
+	}); err != nil {
+		return err
+	}
+	if err := out.writeFile("wasi.cpp", wasiCppTmpl, struct {
+		IncludePath string
+		Namespace   string
+		Runtime     runtimeRef
+	}{
+		IncludePath: incpath,
+		Namespace:   namespace,
+		Runtime:     runtime,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+var wasiHTmpl = template.Must(template.New("wasi.h").Parse(`// Code generated by go2cpp. DO NOT EDIT.
+
+#ifndef {{.IncludeGuard}}
+#define {{.IncludeGuard}}
+
+#include <cstdint>
+#include <functional>
+#include <map>
+#include <string>
+#include <utility>
+#include <vector>
+#include "{{.Runtime.IncludePath}}bytes.h"
+
+namespace {{.Namespace}} {
+{{if .Runtime.Namespace}}
+using namespace {{.Runtime.Namespace}};
+{{end}}
+class Mem;
+
+// Wasi implements the WASI functions (wasi_snapshot_preview1) with the POSIX API.
+//
+// The program can access only the files beneath the preopened directories. Paths are resolved one component at a
+// time, and symbolic links are expanded by Wasi itself so that they cannot refer to files outside of the directories.
+class Wasi {
+public:
+  // Exit is thrown at proc_exit to unwind the program.
+  struct Exit {
+    int32_t code;
+  };
+
+  Wasi();
+  ~Wasi();
+
+  void SetEnv(std::vector<std::string> env);
+  void PreopenDir(std::string guest_path, std::string host_path);
+
+  // Start prepares a new run of the program.
+  void Start(Mem* mem, const std::vector<std::string>& args);
+
+  // Finish closes the files that are still open.
+  void Finish();
+
+{{range $value := .Funcs}}  {{$value.CppDecl}}
+{{end}}
+private:
+  struct File {
+    int fd;
+
+    // owned indicates whether fd is closed with the File.
+    bool owned;
+
+    // preopen is the guest path for a preopened directory.
+    std::string preopen;
+  };
+
+  // Path is a path resolved beneath a preopened directory.
+  class Path {
+  public:
+    Path() = default;
+    ~Path();
+
+    // DirFd returns the host directory that has the file.
+    int DirFd() const;
+
+    // Name returns the file name in the directory. Name is "." for the directory itself.
+    const char* Name() const;
+
+  private:
+    friend class Wasi;
+
+    Path(const Path&) = delete;
+    Path& operator=(const Path&) = delete;
+
+    int root_fd_ = -1;
+
+    // dir_fds_ is the directories opened during the resolution. The last one has the file.
+    std::vector<int> dir_fds_;
+
+    std::string name_ = ".";
+  };
+
+  Wasi(const Wasi&) = delete;
+  Wasi& operator=(const Wasi&) = delete;
+
+  File* GetFile(int32_t fd);
+  int32_t AddFile(File file);
+  void CloseFile(const File& file);
+  std::string LoadString(int32_t ptr, int32_t len);
+  int32_t ResolvePath(int32_t fd, int32_t path, int32_t path_len, bool follow, Path* resolved);
+  int32_t Transfer(int32_t iovs, int32_t iovs_len, int32_t nbytes, const std::function<int64_t(uint8_t*, size_t)>& io);
+  void StoreStrings(const std::vector<std::string>& strs, int32_t ptrs, int32_t buf);
+  void StoreStringsSizes(const std::vector<std::string>& strs, int32_t count, int32_t buf_size);
+
+  Mem* mem_ = nullptr;
+  std::vector<std::string> args_;
+  std::vector<std::string> env_;
+  std::vector<std::pair<std::string, std::string>> preopen_dirs_;
+  std::map<int32_t, File> files_;
+};
+
+}
+
+#endif  // {{.IncludeGuard}}
+`))
+
+var wasiCppTmpl = template.Must(template.New("wasi.cpp").Parse(`// Code generated by go2cpp. DO NOT EDIT.
+
+#include "{{.IncludePath}}wasi.h"
+
+#include <dirent.h>
+#include <fcntl.h>
+#include <poll.h>
+#include <sys/stat.h>
+#include <unistd.h>
+
+#include <algorithm>
+#include <cassert>
+#include <cerrno>
+#include <chrono>
+#include <cstdlib>
+#include <cstring>
+#include <ctime>
+#include <iostream>
+#include <random>
+#include <thread>
+#include "{{.IncludePath}}mem.h"
+
+namespace {{.Namespace}} {
+
+namespace {
+
+void error(const std::string& msg) {
+  std::cerr << msg << std::endl;
+  assert(false);
+  std::exit(1);
+}
+
+constexpr int32_t kErrnoSuccess = 0;
+constexpr int32_t kErrnoBadf = 8;
+constexpr int32_t kErrnoInval = 28;
+constexpr int32_t kErrnoIo = 29;
+constexpr int32_t kErrnoNosys = 52;
+constexpr int32_t kErrnoNotcapable = 76;
+
+constexpr int32_t kClockRealtime = 0;
+constexpr int32_t kClockMonotonic = 1;
+constexpr int32_t kClockProcessCputime = 2;
+constexpr int32_t kClockThreadCputime = 3;
+
+constexpr uint8_t kFiletypeUnknown = 0;
+constexpr uint8_t kFiletypeBlockDevice = 1;
+constexpr uint8_t kFiletypeCharacterDevice = 2;
+constexpr uint8_t kFiletypeDirectory = 3;
+constexpr uint8_t kFiletypeRegularFile = 4;
+constexpr uint8_t kFiletypeSocketStream = 6;
+constexpr uint8_t kFiletypeSymbolicLink = 7;
+
+constexpr int32_t kFdflagAppend = 1 << 0;
+constexpr int32_t kFdflagDsync = 1 << 1;
+constexpr int32_t kFdflagNonblock = 1 << 2;
+constexpr int32_t kFdflagRsync = 1 << 3;
+constexpr int32_t kFdflagSync = 1 << 4;
+
+constexpr int32_t kOflagCreat = 1 << 0;
+constexpr int32_t kOflagDirectory = 1 << 1;
+constexpr int32_t kOflagExcl = 1 << 2;
+constexpr int32_t kOflagTrunc = 1 << 3;
+
+constexpr int32_t kLookupSymlinkFollow = 1 << 0;
+
+// kMaxSymlinks is the maximum number of symbolic links expanded in one path.
+constexpr int kMaxSymlinks = 40;
+
+constexpr int32_t kFstflagAtim = 1 << 0;
+constexpr int32_t kFstflagAtimNow = 1 << 1;
+constexpr int32_t kFstflagMtim = 1 << 2;
+constexpr int32_t kFstflagMtimNow = 1 << 3;
+
+constexpr int64_t kRightFdRead = 1 << 1;
+constexpr int64_t kRightFdWrite = 1 << 6;
+constexpr int64_t kRightFdReaddir = 1 << 14;
+
+constexpr uint8_t kEventtypeClock = 0;
+constexpr uint8_t kEventtypeFdRead = 1;
+constexpr uint8_t kEventtypeFdWrite = 2;
+
+constexpr int32_t kSubscriptionSize = 48;
+constexpr int32_t kEventSize = 32;
+
+// ToErrno converts the host errno to the WASI errno.
+int32_t ToErrno(int err) {
+  static const std::pair<int, int32_t> errnos[] = {
+    {E2BIG, 1}, {EACCES, 2}, {EADDRINUSE, 3}, {EADDRNOTAVAIL, 4}, {EAFNOSUPPORT, 5}, {EAGAIN, 6},
+    {EALREADY, 7}, {EBADF, 8}, {EBADMSG, 9}, {EBUSY, 10}, {ECANCELED, 11}, {ECHILD, 12}, {ECONNABORTED, 13},
+    {ECONNREFUSED, 14}, {ECONNRESET, 15}, {EDEADLK, 16}, {EDESTADDRREQ, 17}, {EDOM, 18}, {EDQUOT, 19},
+    {EEXIST, 20}, {EFAULT, 21}, {EFBIG, 22}, {EHOSTUNREACH, 23}, {EIDRM, 24}, {EILSEQ, 25}, {EINPROGRESS, 26},
+    {EINTR, 27}, {EINVAL, 28}, {EIO, 29}, {EISCONN, 30}, {EISDIR, 31}, {ELOOP, 32}, {EMFILE, 33}, {EMLINK, 34},
+    {EMSGSIZE, 35}, {ENAMETOOLONG, 37}, {ENETDOWN, 38}, {ENETRESET, 39}, {ENETUNREACH, 40}, {ENFILE, 41},
+    {ENOBUFS, 42}, {ENODEV, 43}, {ENOENT, 44}, {ENOEXEC, 45}, {ENOLCK, 46}, {ENOMEM, 48}, {ENOMSG, 49},
+    {ENOPROTOOPT, 50}, {ENOSPC, 51}, {ENOSYS, 52}, {ENOTCONN, 53}, {ENOTDIR, 54}, {ENOTEMPTY, 55},
+    {ENOTSOCK, 57}, {ENOTSUP, 58}, {ENOTTY, 59}, {ENXIO, 60}, {EOVERFLOW, 61}, {EPERM, 63}, {EPIPE, 64},
+    {EPROTO, 65}, {EPROTONOSUPPORT, 66}, {EPROTOTYPE, 67}, {ERANGE, 68}, {EROFS, 69}, {ESPIPE, 70},
+    {ESRCH, 71}, {ESTALE, 72}, {ETIMEDOUT, 73}, {ETXTBSY, 74}, {EXDEV, 75},
+  };
+  for (const auto& e : errnos) {
+    if (e.first == err) {
+      return e.second;
+    }
+  }
+  return kErrnoIo;
+}
+
+uint8_t ToFiletype(mode_t mode) {
+  switch (mode & S_IFMT) {
+  case S_IFBLK:
+    return kFiletypeBlockDevice;
+  case S_IFCHR:
+    return kFiletypeCharacterDevice;
+  case S_IFDIR:
+    return kFiletypeDirectory;
+  case S_IFREG:
+    return kFiletypeRegularFile;
+  case S_IFSOCK:
+    return kFiletypeSocketStream;
+  case S_IFLNK:
+    return kFiletypeSymbolicLink;
+  }
+  return kFiletypeUnknown;
+}
+
+uint8_t ToFiletypeFromDirent(unsigned char type) {
+  switch (type) {
+  case DT_BLK:
+    return kFiletypeBlockDevice;
+  case DT_CHR:
+    return kFiletypeCharacterDevice;
+  case DT_DIR:
+    return kFiletypeDirectory;
+  case DT_REG:
+    return kFiletypeRegularFile;
+  case DT_SOCK:
+    return kFiletypeSocketStream;
+  case DT_LNK:
+    return kFiletypeSymbolicLink;
+  }
+  return kFiletypeUnknown;
+}
+
+int64_t ToTimestamp(const struct timespec& ts) {
+  return static_cast<int64_t>(ts.tv_sec) * 1000000000 + ts.tv_nsec;
+}
+
+void StoreFilestat(Mem* mem, int32_t buf, const struct stat& st) {
+  mem->StoreInt64(buf, static_cast<int64_t>(st.st_dev));
+  mem->StoreInt64(buf + 8, static_cast<int64_t>(st.st_ino));
+  mem->StoreInt64(buf + 16, ToFiletype(st.st_mode));
+  mem->StoreInt64(buf + 24, static_cast<int64_t>(st.st_nlink));
+  mem->StoreInt64(buf + 32, static_cast<int64_t>(st.st_size));
+#if defined(__APPLE__)
+  mem->StoreInt64(buf + 40, ToTimestamp(st.st_atimespec));
+  mem->StoreInt64(buf + 48, ToTimestamp(st.st_mtimespec));
+  mem->StoreInt64(buf + 56, ToTimestamp(st.st_ctimespec));
+#else
+  mem->StoreInt64(buf + 40, ToTimestamp(st.st_atim));
+  mem->StoreInt64(buf + 48, ToTimestamp(st.st_mtim));
+  mem->StoreInt64(buf + 56, ToTimestamp(st.st_ctim));
+#endif
+}
+
+void ToTimespecs(int64_t atim, int64_t mtim, int32_t fst_flags, struct timespec times[2]) {
+  auto to_timespec = [](int64_t t, bool set, bool now) -> struct timespec {
+    struct timespec ts = {};
+    if (now) {
+      ts.tv_nsec = UTIME_NOW;
+    } else if (set) {
+      ts.tv_sec = t / 1000000000;
+      ts.tv_nsec = t % 1000000000;
+    } else {
+      ts.tv_nsec = UTIME_OMIT;
+    }
+    return ts;
+  };
+  times[0] = to_timespec(atim, fst_flags & kFstflagAtim, fst_flags & kFstflagAtimNow);
+  times[1] = to_timespec(mtim, fst_flags & kFstflagMtim, fst_flags & kFstflagMtimNow);
+}
+
+// PushPathElems pushes the components of the slash-separated path to elems in the reverse order, so that the first
+// component is the back of elems.
+void PushPathElems(const std::string& path, std::vector<std::string>* elems) {
+  std::vector<std::string> split;
+  size_t start = 0;
+  while (true) {
+    size_t end = path.find('/', start);
+    if (end == std::string::npos) {
+      split.push_back(path.substr(start));
+      break;
+    }
+    split.push_back(path.substr(start, end - start));
+    start = end + 1;
+  }
+  elems->insert(elems->end(), split.rbegin(), split.rend());
+}
+
+// ReadLink reads the target of the symbolic link name in the directory dir_fd.
+// ReadLink returns false if name is not a symbolic link.
+bool ReadLink(int dir_fd, const std::string& name, std::string* target) {
+  std::vector<char> buf(256);
+  while (true) {
+    ssize_t n = ::readlinkat(dir_fd, name.c_str(), buf.data(), buf.size());
+    if (n < 0) {
+      return false;
+    }
+    if (static_cast<size_t>(n) < buf.size()) {
+      target->assign(buf.data(), n);
+      return true;
+    }
+    buf.resize(buf.size() * 2);
+  }
+}
+
+int64_t Now(int32_t clock_id) {
+  switch (clock_id) {
+  case kClockRealtime:
+    return std::chrono::duration_cast<std::chrono::nanoseconds>(
+        std::chrono::system_clock::now().time_since_epoch()).count();
+  case kClockMonotonic:
+    return std::chrono::duration_cast<std::chrono::nanoseconds>(
+        std::chrono::steady_clock::now().time_since_epoch()).count();
+  case kClockProcessCputime:
+  case kClockThreadCputime:
+    return static_cast<int64_t>(std::clock()) * 1000000000 / CLOCKS_PER_SEC;
+  }
+  return -1;
+}
+
+}
+
+Wasi::Wasi() = default;
+
+Wasi::~Wasi() {
+  Finish();
+}
+
+void Wasi::SetEnv(std::vector<std::string> env) {
+  env_ = std::move(env);
+}
+
+void Wasi::PreopenDir(std::string guest_path, std::string host_path) {
+  preopen_dirs_.emplace_back(std::move(guest_path), std::move(host_path));
+}
+
+void Wasi::Start(Mem* mem, const std::vector<std::string>& args) {
+  Finish();
+
+  mem_ = mem;
+  args_ = args;
+  for (int fd = 0; fd < 3; fd++) {
+    files_[fd] = File{fd, false, ""};
+  }
+  for (const auto& dir : preopen_dirs_) {
+    int fd = ::open(dir.second.c_str(), O_RDONLY | O_DIRECTORY | O_CLOEXEC);
+    if (fd < 0) {
+      error("failed to open the preopened directory " + dir.second + ": " + std::strerror(errno));
+    }
+    AddFile(File{fd, true, dir.first});
+  }
+}
+
+void Wasi::Finish() {
+  for (const auto& f : files_) {
+    CloseFile(f.second);
+  }
+  files_.clear();
+}
+
+Wasi::File* Wasi::GetFile(int32_t fd) {
+  auto it = files_.find(fd);
+  if (it == files_.end()) {
+    return nullptr;
+  }
+  return &it->second;
+}
+
+int32_t Wasi::AddFile(File file) {
+  int32_t fd = 0;
+  while (files_.find(fd) != files_.end()) {
+    fd++;
+  }
+  files_[fd] = std::move(file);
+  return fd;
+}
+
+void Wasi::CloseFile(const File& file) {
+  if (file.owned) {
+    ::close(file.fd);
+  }
+}
+
+std::string Wasi::LoadString(int32_t ptr, int32_t len) {
+  BytesSpan bs = mem_->LoadSliceDirectly(static_cast<uint32_t>(ptr), len);
+  return std::string{bs.begin(), bs.end()};
+}
+
+Wasi::Path::~Path() {
+  for (int fd : dir_fds_) {
+    ::close(fd);
+  }
+}
+
+int Wasi::Path::DirFd() const {
+  if (dir_fds_.empty()) {
+    return root_fd_;
+  }
+  return dir_fds_.back();
+}
+
+const char* Wasi::Path::Name() const {
+  return name_.c_str();
+}
+
+// ResolvePath resolves the path relative to the directory fd.
+//
+// The directories in the path are opened one by one without following symbolic links. A symbolic link is expanded
+// by reading it, and the expanded path is resolved in the same way. Then, neither .. nor symbolic links can go out of
+// the directory fd. The last component is expanded only when follow is true.
+int32_t Wasi::ResolvePath(int32_t fd, int32_t path, int32_t path_len, bool follow, Path* resolved) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  std::string p = LoadString(path, path_len);
+  if (p.empty() || p[0] == '/') {
+    return kErrnoNotcapable;
+  }
+  // Trailing slashes don't make a component. The last component is the file name.
+  while (p.size() > 1 && p.back() == '/') {
+    p.pop_back();
+  }
+  resolved->root_fd_ = file->fd;
+
+  std::vector<std::string> elems;
+  PushPathElems(p, &elems);
+  int links = 0;
+  while (!elems.empty()) {
+    std::string elem = std::move(elems.back());
+    elems.pop_back();
+    if (elem.empty() || elem == ".") {
+      continue;
+    }
+    if (elem == "..") {
+      if (resolved->dir_fds_.empty()) {
+        return kErrnoNotcapable;
+      }
+      ::close(resolved->dir_fds_.back());
+      resolved->dir_fds_.pop_back();
+      continue;
+    }
+
+    bool last = elems.empty();
+    std::string target;
+    if ((!last || follow) && ReadLink(resolved->DirFd(), elem, &target)) {
+      links++;
+      if (links > kMaxSymlinks) {
+        return ToErrno(ELOOP);
+      }
+      if (target.empty() || target[0] == '/') {
+        return kErrnoNotcapable;
+      }
+      PushPathElems(target, &elems);
+      continue;
+    }
+    if (last) {
+      resolved->name_ = elem;
+      break;
+    }
+
+    // O_NOFOLLOW makes this fail if elem is replaced with a symbolic link after ReadLink.
+    int dir_fd = ::openat(resolved->DirFd(), elem.c_str(), O_RDONLY | O_DIRECTORY | O_NOFOLLOW | O_CLOEXEC);
+    if (dir_fd < 0) {
+      return ToErrno(errno);
+    }
+    resolved->dir_fds_.push_back(dir_fd);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::Transfer(int32_t iovs, int32_t iovs_len, int32_t nbytes, const std::function<int64_t(uint8_t*, size_t)>& io) {
+  int32_t total = 0;
+  for (int32_t i = 0; i < iovs_len; i++) {
+    int32_t ptr = mem_->LoadInt32(iovs + i * 8);
+    int32_t len = mem_->LoadInt32(iovs + i * 8 + 4);
+    BytesSpan buf = mem_->LoadSliceDirectly(static_cast<uint32_t>(ptr), len);
+    int64_t n = io(buf.begin(), buf.size());
+    if (n < 0) {
+      if (total == 0) {
+        return ToErrno(errno);
+      }
+      break;
+    }
+    total += static_cast<int32_t>(n);
+    if (n < buf.size()) {
+      break;
+    }
+  }
+  mem_->StoreInt32(nbytes, total);
+  return kErrnoSuccess;
+}
+
+void Wasi::StoreStrings(const std::vector<std::string>& strs, int32_t ptrs, int32_t buf) {
+  for (const std::string& str : strs) {
+    mem_->StoreInt32(ptrs, buf);
+    ptrs += 4;
+    std::vector<uint8_t> bytes(str.begin(), str.end());
+    bytes.push_back('\0');
+    mem_->StoreBytes(buf, bytes);
+    buf += bytes.size();
+  }
+}
+
+void Wasi::StoreStringsSizes(const std::vector<std::string>& strs, int32_t count, int32_t buf_size) {
+  int32_t size = 0;
+  for (const std::string& str : strs) {
+    size += str.size() + 1;
+  }
+  mem_->StoreInt32(count, static_cast<int32_t>(strs.size()));
+  mem_->StoreInt32(buf_size, size);
+}
+
+int32_t Wasi::ArgsGet(int32_t argv, int32_t argv_buf) {
+  StoreStrings(args_, argv, argv_buf);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::ArgsSizesGet(int32_t argc, int32_t argv_buf_size) {
+  StoreStringsSizes(args_, argc, argv_buf_size);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::EnvironGet(int32_t environ, int32_t environ_buf) {
+  StoreStrings(env_, environ, environ_buf);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::EnvironSizesGet(int32_t environc, int32_t environ_buf_size) {
+  StoreStringsSizes(env_, environc, environ_buf_size);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::ClockResGet(int32_t id, int32_t resolution) {
+  if (Now(id) < 0) {
+    return kErrnoInval;
+  }
+  mem_->StoreInt64(resolution, 1);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::ClockTimeGet(int32_t id, int64_t precision, int32_t time) {
+  int64_t now = Now(id);
+  if (now < 0) {
+    return kErrnoInval;
+  }
+  mem_->StoreInt64(time, now);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdAdvise(int32_t fd, int64_t offset, int64_t len, int32_t advice) {
+  if (!GetFile(fd)) {
+    return kErrnoBadf;
+  }
+  // Advices are just hints.
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdAllocate(int32_t fd, int64_t offset, int64_t len) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  struct stat st;
+  if (::fstat(file->fd, &st) < 0) {
+    return ToErrno(errno);
+  }
+  if (st.st_size < offset + len && ::ftruncate(file->fd, offset + len) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdClose(int32_t fd) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  CloseFile(*file);
+  files_.erase(fd);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdDatasync(int32_t fd) {
+  return FdSync(fd);
+}
+
+int32_t Wasi::FdFdstatGet(int32_t fd, int32_t stat) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  struct stat st;
+  if (::fstat(file->fd, &st) < 0) {
+    return ToErrno(errno);
+  }
+  int fl = ::fcntl(file->fd, F_GETFL);
+  if (fl < 0) {
+    return ToErrno(errno);
+  }
+  int32_t flags = 0;
+  if (fl & O_APPEND) {
+    flags |= kFdflagAppend;
+  }
+  if (fl & O_NONBLOCK) {
+    flags |= kFdflagNonblock;
+  }
+  mem_->StoreInt8(stat, ToFiletype(st.st_mode));
+  mem_->StoreInt16(stat + 2, static_cast<int16_t>(flags));
+  // Rights are not restricted.
+  mem_->StoreInt64(stat + 8, -1);
+  mem_->StoreInt64(stat + 16, -1);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdFdstatSetFlags(int32_t fd, int32_t flags) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  int fl = ::fcntl(file->fd, F_GETFL);
+  if (fl < 0) {
+    return ToErrno(errno);
+  }
+  fl &= ~(O_APPEND | O_NONBLOCK);
+  if (flags & kFdflagAppend) {
+    fl |= O_APPEND;
+  }
+  if (flags & kFdflagNonblock) {
+    fl |= O_NONBLOCK;
+  }
+  if (::fcntl(file->fd, F_SETFL, fl) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdFdstatSetRights(int32_t fd, int64_t rights_base, int64_t rights_inheriting) {
+  if (!GetFile(fd)) {
+    return kErrnoBadf;
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdFilestatGet(int32_t fd, int32_t buf) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  struct stat st;
+  if (::fstat(file->fd, &st) < 0) {
+    return ToErrno(errno);
+  }
+  StoreFilestat(mem_, buf, st);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdFilestatSetSize(int32_t fd, int64_t size) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  if (::ftruncate(file->fd, size) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdFilestatSetTimes(int32_t fd, int64_t atim, int64_t mtim, int32_t fst_flags) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  struct timespec times[2];
+  ToTimespecs(atim, mtim, fst_flags, times);
+  if (::futimens(file->fd, times) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdPread(int32_t fd, int32_t iovs, int32_t iovs_len, int64_t offset, int32_t nread) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  int host_fd = file->fd;
+  return Transfer(iovs, iovs_len, nread, [host_fd, &offset](uint8_t* buf, size_t size) -> int64_t {
+    ssize_t n = ::pread(host_fd, buf, size, offset);
+    if (n > 0) {
+      offset += n;
+    }
+    return n;
+  });
+}
+
+int32_t Wasi::FdPrestatGet(int32_t fd, int32_t buf) {
+  File* file = GetFile(fd);
+  if (!file || file->preopen.empty()) {
+    return kErrnoBadf;
+  }
+  // The tag 0 is for a directory.
+  mem_->StoreInt8(buf, 0);
+  mem_->StoreInt32(buf + 4, static_cast<int32_t>(file->preopen.size()));
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdPrestatDirName(int32_t fd, int32_t path, int32_t path_len) {
+  File* file = GetFile(fd);
+  if (!file || file->preopen.empty()) {
+    return kErrnoBadf;
+  }
+  std::vector<uint8_t> bytes(file->preopen.begin(), file->preopen.end());
+  bytes.resize(std::min(bytes.size(), static_cast<size_t>(path_len)));
+  mem_->StoreBytes(path, bytes);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdPwrite(int32_t fd, int32_t iovs, int32_t iovs_len, int64_t offset, int32_t nwritten) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  int host_fd = file->fd;
+  return Transfer(iovs, iovs_len, nwritten, [host_fd, &offset](uint8_t* buf, size_t size) -> int64_t {
+    ssize_t n = ::pwrite(host_fd, buf, size, offset);
+    if (n > 0) {
+      offset += n;
+    }
+    return n;
+  });
+}
+
+int32_t Wasi::FdRead(int32_t fd, int32_t iovs, int32_t iovs_len, int32_t nread) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  int host_fd = file->fd;
+  return Transfer(iovs, iovs_len, nread, [host_fd](uint8_t* buf, size_t size) -> int64_t {
+    return ::read(host_fd, buf, size);
+  });
+}
+
+int32_t Wasi::FdReaddir(int32_t fd, int32_t buf, int32_t buf_len, int64_t cookie, int32_t bufused) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  // fdopendir takes the ownership of the file descriptor.
+  int dir_fd = ::dup(file->fd);
+  if (dir_fd < 0) {
+    return ToErrno(errno);
+  }
+  DIR* dir = ::fdopendir(dir_fd);
+  if (!dir) {
+    int err = errno;
+    ::close(dir_fd);
+    return ToErrno(err);
+  }
+  ::rewinddir(dir);
+
+  // The cookie is the index of the next entry. An entry that doesn't fit the buffer is truncated.
+  int64_t index = 0;
+  int32_t used = 0;
+  while (used < buf_len) {
+    errno = 0;
+    struct dirent* ent = ::readdir(dir);
+    if (!ent) {
+      if (errno) {
+        int err = errno;
+        ::closedir(dir);
+        return ToErrno(err);
+      }
+      break;
+    }
+    index++;
+    if (index <= cookie) {
+      continue;
+    }
+    uint32_t name_len = std::strlen(ent->d_name);
+    std::vector<uint8_t> entry(24 + name_len);
+    uint64_t ino = ent->d_ino;
+    std::memcpy(&entry[0], &index, 8);
+    std::memcpy(&entry[8], &ino, 8);
+    std::memcpy(&entry[16], &name_len, 4);
+    entry[20] = ToFiletypeFromDirent(ent->d_type);
+    std::memcpy(&entry[24], ent->d_name, name_len);
+    entry.resize(std::min(entry.size(), static_cast<size_t>(buf_len - used)));
+    mem_->StoreBytes(buf + used, entry);
+    used += entry.size();
+  }
+  ::closedir(dir);
+  mem_->StoreInt32(bufused, used);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdRenumber(int32_t fd, int32_t to) {
+  File* file = GetFile(fd);
+  File* to_file = GetFile(to);
+  if (!file || !to_file) {
+    return kErrnoBadf;
+  }
+  if (fd == to) {
+    return kErrnoSuccess;
+  }
+  CloseFile(*to_file);
+  files_[to] = std::move(*file);
+  files_.erase(fd);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdSeek(int32_t fd, int64_t offset, int32_t whence, int32_t newoffset) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  int w = 0;
+  switch (whence) {
+  case 0:
+    w = SEEK_SET;
+    break;
+  case 1:
+    w = SEEK_CUR;
+    break;
+  case 2:
+    w = SEEK_END;
+    break;
+  default:
+    return kErrnoInval;
+  }
+  off_t result = ::lseek(file->fd, offset, w);
+  if (result < 0) {
+    return ToErrno(errno);
+  }
+  mem_->StoreInt64(newoffset, result);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdSync(int32_t fd) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  if (::fsync(file->fd) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::FdTell(int32_t fd, int32_t offset) {
+  return FdSeek(fd, 0, 1, offset);
+}
+
+int32_t Wasi::FdWrite(int32_t fd, int32_t iovs, int32_t iovs_len, int32_t nwritten) {
+  File* file = GetFile(fd);
+  if (!file) {
+    return kErrnoBadf;
+  }
+  int host_fd = file->fd;
+  return Transfer(iovs, iovs_len, nwritten, [host_fd](uint8_t* buf, size_t size) -> int64_t {
+    return ::write(host_fd, buf, size);
+  });
+}
+
+int32_t Wasi::PathCreateDirectory(int32_t fd, int32_t path, int32_t path_len) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, false, &p)) {
+    return e;
+  }
+  if (::mkdirat(p.DirFd(), p.Name(), 0777) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathFilestatGet(int32_t fd, int32_t flags, int32_t path, int32_t path_len, int32_t buf) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, flags & kLookupSymlinkFollow, &p)) {
+    return e;
+  }
+  struct stat st;
+  if (::fstatat(p.DirFd(), p.Name(), &st, AT_SYMLINK_NOFOLLOW) < 0) {
+    return ToErrno(errno);
+  }
+  StoreFilestat(mem_, buf, st);
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathFilestatSetTimes(int32_t fd, int32_t flags, int32_t path, int32_t path_len, int64_t atim, int64_t mtim, int32_t fst_flags) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, flags & kLookupSymlinkFollow, &p)) {
+    return e;
+  }
+  struct timespec times[2];
+  ToTimespecs(atim, mtim, fst_flags, times);
+  if (::utimensat(p.DirFd(), p.Name(), times, AT_SYMLINK_NOFOLLOW) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathLink(int32_t old_fd, int32_t old_flags, int32_t old_path, int32_t old_path_len, int32_t new_fd, int32_t new_path, int32_t new_path_len) {
+  Path op;
+  if (int32_t e = ResolvePath(old_fd, old_path, old_path_len, old_flags & kLookupSymlinkFollow, &op)) {
+    return e;
+  }
+  Path np;
+  if (int32_t e = ResolvePath(new_fd, new_path, new_path_len, false, &np)) {
+    return e;
+  }
+  if (::linkat(op.DirFd(), op.Name(), np.DirFd(), np.Name(), 0) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathOpen(int32_t fd, int32_t dirflags, int32_t path, int32_t path_len, int32_t oflags, int64_t rights_base, int64_t rights_inheriting, int32_t fdflags, int32_t opened_fd) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, dirflags & kLookupSymlinkFollow, &p)) {
+    return e;
+  }
+
+  int flags = O_CLOEXEC;
+  bool read = rights_base & (kRightFdRead | kRightFdReaddir);
+  bool write = rights_base & kRightFdWrite;
+  if (read && write) {
+    flags |= O_RDWR;
+  } else if (write) {
+    flags |= O_WRONLY;
+  } else {
+    flags |= O_RDONLY;
+  }
+  if (oflags & kOflagCreat) {
+    flags |= O_CREAT;
+  }
+  if (oflags & kOflagDirectory) {
+    flags |= O_DIRECTORY;
+  }
+  if (oflags & kOflagExcl) {
+    flags |= O_EXCL;
+  }
+  if (oflags & kOflagTrunc) {
+    flags |= O_TRUNC;
+  }
+  if (fdflags & kFdflagAppend) {
+    flags |= O_APPEND;
+  }
+  if (fdflags & kFdflagNonblock) {
+    flags |= O_NONBLOCK;
+  }
+  if (fdflags & (kFdflagDsync | kFdflagRsync | kFdflagSync)) {
+    flags |= O_SYNC;
+  }
+  // A symbolic link is already expanded by ResolvePath if needed.
+  flags |= O_NOFOLLOW;
+
+  int host_fd = ::openat(p.DirFd(), p.Name(), flags, 0666);
+  if (host_fd < 0) {
+    return ToErrno(errno);
+  }
+  mem_->StoreInt32(opened_fd, AddFile(File{host_fd, true, ""}));
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathReadlink(int32_t fd, int32_t path, int32_t path_len, int32_t buf, int32_t buf_len, int32_t bufused) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, false, &p)) {
+    return e;
+  }
+  BytesSpan bs = mem_->LoadSliceDirectly(static_cast<uint32_t>(buf), buf_len);
+  ssize_t n = ::readlinkat(p.DirFd(), p.Name(), reinterpret_cast<char*>(bs.begin()), bs.size());
+  if (n < 0) {
+    return ToErrno(errno);
+  }
+  mem_->StoreInt32(bufused, static_cast<int32_t>(n));
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathRemoveDirectory(int32_t fd, int32_t path, int32_t path_len) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, false, &p)) {
+    return e;
+  }
+  if (::unlinkat(p.DirFd(), p.Name(), AT_REMOVEDIR) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathRename(int32_t fd, int32_t old_path, int32_t old_path_len, int32_t new_fd, int32_t new_path, int32_t new_path_len) {
+  Path op;
+  if (int32_t e = ResolvePath(fd, old_path, old_path_len, false, &op)) {
+    return e;
+  }
+  Path np;
+  if (int32_t e = ResolvePath(new_fd, new_path, new_path_len, false, &np)) {
+    return e;
+  }
+  if (::renameat(op.DirFd(), op.Name(), np.DirFd(), np.Name()) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathSymlink(int32_t old_path, int32_t old_path_len, int32_t fd, int32_t new_path, int32_t new_path_len) {
+  Path np;
+  if (int32_t e = ResolvePath(fd, new_path, new_path_len, false, &np)) {
+    return e;
+  }
+  std::string target = LoadString(old_path, old_path_len);
+  if (::symlinkat(target.c_str(), np.DirFd(), np.Name()) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PathUnlinkFile(int32_t fd, int32_t path, int32_t path_len) {
+  Path p;
+  if (int32_t e = ResolvePath(fd, path, path_len, false, &p)) {
+    return e;
+  }
+  if (::unlinkat(p.DirFd(), p.Name(), 0) < 0) {
+    return ToErrno(errno);
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::PollOneoff(int32_t in, int32_t out, int32_t nsubscriptions, int32_t nevents) {
+  if (nsubscriptions <= 0) {
+    return kErrnoInval;
+  }
+
+  struct Event {
+    int64_t userdata;
+    int32_t error;
+    uint8_t type;
+    int16_t flags;
+  };
+  std::vector<Event> events;
+
+  // timeout is the nanoseconds until the earliest clock subscription. -1 means no clock subscriptions.
+  int64_t timeout = -1;
+  std::vector<int64_t> timeouts(nsubscriptions, -1);
+  std::vector<struct pollfd> fds;
+  std::vector<int32_t> fd_subscriptions;
+  for (int32_t i = 0; i < nsubscriptions; i++) {
+    int32_t sub = in + i * kSubscriptionSize;
+    int64_t userdata = mem_->LoadInt64(sub);
+    uint8_t type = mem_->LoadUint8(sub + 8);
+    switch (type) {
+    case kEventtypeClock: {
+      int32_t id = mem_->LoadInt32(sub + 16);
+      int64_t t = mem_->LoadInt64(sub + 24);
+      // The flag 1 is for an absolute time.
+      if (mem_->LoadUint16(sub + 40) & 1) {
+        int64_t now = Now(id);
+        if (now < 0) {
+          events.push_back(Event{userdata, kErrnoInval, type, 0});
+          continue;
+        }
+        t -= now;
+      }
+      t = std::max(t, static_cast<int64_t>(0));
+      timeouts[i] = t;
+      if (timeout < 0 || t < timeout) {
+        timeout = t;
+      }
+      break;
+    }
+    case kEventtypeFdRead:
+    case kEventtypeFdWrite: {
+      File* file = GetFile(mem_->LoadInt32(sub + 16));
+      if (!file) {
+        events.push_back(Event{userdata, kErrnoBadf, type, 0});
+        continue;
+      }
+      struct pollfd pfd = {};
+      pfd.fd = file->fd;
+      pfd.events = type == kEventtypeFdRead ? POLLIN : POLLOUT;
+      fds.push_back(pfd);
+      fd_subscriptions.push_back(i);
+      break;
+    }
+    default:
+      events.push_back(Event{userdata, kErrnoInval, type, 0});
+      break;
+    }
+  }
+
+  // Errors are reported without waiting.
+  if (!events.empty()) {
+    timeout = 0;
+  }
+  auto start = std::chrono::steady_clock::now();
+  if (fds.empty()) {
+    if (timeout > 0) {
+      std::this_thread::sleep_for(std::chrono::nanoseconds(timeout));
+    }
+  } else {
+    int timeout_ms = -1;
+    if (timeout >= 0) {
+      timeout_ms = static_cast<int>((timeout + 999999) / 1000000);
+    }
+    if (::poll(fds.data(), fds.size(), timeout_ms) < 0 && errno != EINTR) {
+      return ToErrno(errno);
+    }
+  }
+  int64_t elapsed = std::chrono::duration_cast<std::chrono::nanoseconds>(std::chrono::steady_clock::now() - start).count();
+
+  for (size_t i = 0; i < fds.size(); i++) {
+    if (!fds[i].revents) {
+      continue;
+    }
+    int32_t sub = in + fd_subscriptions[i] * kSubscriptionSize;
+    int32_t error = kErrnoSuccess;
+    if (fds[i].revents & POLLNVAL) {
+      error = kErrnoBadf;
+    }
+    // The flag 1 is for a hangup.
+    events.push_back(Event{mem_->LoadInt64(sub), error, mem_->LoadUint8(sub + 8), static_cast<int16_t>((fds[i].revents & POLLHUP) ? 1 : 0)});
+  }
+  for (int32_t i = 0; i < nsubscriptions; i++) {
+    if (timeouts[i] < 0 || timeouts[i] > elapsed) {
+      continue;
+    }
+    int32_t sub = in + i * kSubscriptionSize;
+    events.push_back(Event{mem_->LoadInt64(sub), kErrnoSuccess, kEventtypeClock, 0});
+  }
+
+  for (size_t i = 0; i < events.size(); i++) {
+    int32_t e = out + i * kEventSize;
+    mem_->StoreInt64(e, events[i].userdata);
+    mem_->StoreInt16(e + 8, static_cast<int16_t>(events[i].error));
+    mem_->StoreInt8(e + 10, events[i].type);
+    mem_->StoreInt64(e + 16, 0);
+    mem_->StoreInt16(e + 24, events[i].flags);
+  }
+  mem_->StoreInt32(nevents, static_cast<int32_t>(events.size()));
+  return kErrnoSuccess;
+}
+
+void Wasi::ProcExit(int32_t code) {
+  throw Exit{code};
+}
+
+int32_t Wasi::ProcRaise(int32_t sig) {
+  return kErrnoNosys;
+}
+
+int32_t Wasi::RandomGet(int32_t buf, int32_t buf_len) {
+  // TODO: Use cryptographically strong random values instead of std::random_device.
+  static std::random_device rd;
+  std::uniform_int_distribution<uint16_t> dist(0, 255);
+  BytesSpan bs = mem_->LoadSliceDirectly(static_cast<uint32_t>(buf), buf_len);
+  for (size_t i = 0; i < bs.size(); i++) {
+    bs[i] = static_cast<uint8_t>(dist(rd));
+  }
+  return kErrnoSuccess;
+}
+
+int32_t Wasi::SchedYield() {
+  std::this_thread::yield();
+  return kErrnoSuccess;
+}
+
+// Sockets are not implemented.
+
+int32_t Wasi::SockAccept(int32_t fd, int32_t flags, int32_t opened_fd) {
+  return kErrnoNosys;
+}
+
+int32_t Wasi::SockRecv(int32_t fd, int32_t ri_data, int32_t ri_data_len, int32_t ri_flags, int32_t ro_datalen, int32_t ro_flags) {
+  return kErrnoNosys;
+}
+
+int32_t Wasi::SockSend(int32_t fd, int32_t si_data, int32_t si_data_len, int32_t si_flags, int32_t so_datalen) {
+  return kErrnoNosys;
+}
+
+int32_t Wasi::SockShutdown(int32_t fd, int32_t how) {
+  return kErrnoNosys;
+}
+
+}
+`))
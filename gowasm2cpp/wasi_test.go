@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package gowasm2cpp
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hajimehoshi/go2cpp/internal/wasm"
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// wasiTestModule is a Wasm module that imports wasi_snapshot_preview1.fd_write twice and
+// wasi_snapshot_preview1.proc_exit, and has one empty function exported as _start, and one page of memory.
+var wasiTestModule = Module(
+	Section(SectionType, Vec(
+		FuncType([]byte{I32, I32, I32, I32}, []byte{I32}),
+		FuncType([]byte{I32}, nil),
+		FuncType(nil, nil),
+	)...),
+	Section(SectionImport, Vec(
+		ImportFunc("wasi_snapshot_preview1", "fd_write", 0),
+		ImportFunc("wasi_snapshot_preview1", "fd_write", 0),
+		ImportFunc("wasi_snapshot_preview1", "proc_exit", 1),
+	)...),
+	Section(SectionFunction, Vec(U32(2))...),
+	Section(SectionMemory, Vec(Limits(1))...),
+	Section(SectionExport, Vec(Export("_start", KindFunction, 3), Export("memory", KindMemory, 0))...),
+	Section(SectionCode, Vec(Body())...),
+)
+
+func TestGenerateWASI(t *testing.T) {
+	out := MapOutput{}
+	if err := GenerateWithConfig(context.Background(), Config{
+		Namespace: "foo",
+		Module:    wasiTestModule,
+		Output:    out,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"wasi.h", "wasi.cpp"} {
+		if _, ok := out[name]; !ok {
+			t.Errorf("%s is not in the output", name)
+		}
+	}
+	if got, want := out["go.cpp"], []byte("inst_->_start();"); !bytes.Contains(got, want) {
+		t.Errorf("go.cpp doesn't have %q", want)
+	}
+	// The imports of the same function share one C++ function.
+	if got, want := bytes.Count(out["go.cpp"], []byte("return go_->wasi_.FdWrite(local0_, local1_, local2_, local3_);")), 1; got != want {
+		t.Errorf("the number of fd_write bodies: got: %d, want: %d", got, want)
+	}
+	if got, want := out["go.cpp"], []byte("go_->wasi_.ProcExit(local0_);"); !bytes.Contains(got, want) {
+		t.Errorf("go.cpp doesn't have %q", want)
+	}
+	if got, want := out["wasi.h"], []byte("int32_t FdWrite(int32_t arg0, int32_t arg1, int32_t arg2, int32_t arg3);"); !bytes.Contains(got, want) {
+		t.Errorf("wasi.h doesn't have %q", want)
+	}
+	if bytes.Contains(out["host.h"], []byte("wasi_snapshot_preview1_")) {
+		t.Errorf("host.h must not have WASI functions")
+	}
+
+	// A WASI module uses neither JavaScript nor the game loop.
+	for _, name := range []string{"js.h", "js.cpp", "taskqueue.h", "taskqueue.cpp", "gl.h", "gl.cpp", "game.h", "game.cpp"} {
+		if _, ok := out[name]; ok {
+			t.Errorf("%s must not be in the output", name)
+		}
+	}
+	for _, name := range []string{"go.h", "go.cpp"} {
+		for _, s := range []string{"js.h", "GoObject", "Value", "TaskQueue", "Resume"} {
+			if bytes.Contains(out[name], []byte(s)) {
+				t.Errorf("%s must not have %q", name, s)
+			}
+		}
+	}
+}
+
+func TestGenerateWASIError(t *testing.T) {
+	noStart := bytes.Replace(wasiTestModule, []byte("_start"), []byte("_begin"), 1)
+
+	sigMismatch := append([]byte{}, wasiTestModule...)
+	// Change the type of the second fd_write to (i32) -> ().
+	sigMismatch[bytes.LastIndex(sigMismatch, []byte("fd_write"))+len("fd_write")+1] = 0x01
+
+	for _, m := range [][]byte{noStart, sigMismatch} {
+		if err := GenerateWithConfig(context.Background(), Config{
+			Module: m,
+			Output: MapOutput{},
+		}); err == nil {
+			t.Errorf("GenerateWithConfig must fail")
+		}
+	}
+}
+
+func TestWASIFuncsRegistered(t *testing.T) {
+	r := DefaultRegistry()
+	for _, f := range wasiFuncs {
+		params, results, err := parseSignature(f.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := r.body(wasiModule+"."+f.Name, &wasm.FunctionSig{ParamTypes: params, ReturnTypes: results}); !ok || err != nil {
+			t.Errorf("%s is not registered: %v", f.Name, err)
+		}
+	}
+}
@@ -0,0 +1,557 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	sectionCustom    = 0
+	sectionType      = 1
+	sectionImport    = 2
+	sectionFunction  = 3
+	sectionTable     = 4
+	sectionMemory    = 5
+	sectionGlobal    = 6
+	sectionExport    = 7
+	sectionStart     = 8
+	sectionElement   = 9
+	sectionCode      = 10
+	sectionData      = 11
+	sectionDataCount = 12
+)
+
+// maxLocals is the maximum number of the locals of a function. The limit is the same as the one of the major Wasm
+// engines, and prevents a malformed module from making the translator allocate too many locals.
+const maxLocals = 50000
+
+// sectionOrders is the order of the non-custom sections. The data count section is between the element and the code
+// sections.
+var sectionOrders = map[byte]int{
+	sectionType:      1,
+	sectionImport:    2,
+	sectionFunction:  3,
+	sectionTable:     4,
+	sectionMemory:    5,
+	sectionGlobal:    6,
+	sectionExport:    7,
+	sectionStart:     8,
+	sectionElement:   9,
+	sectionDataCount: 10,
+	sectionCode:      11,
+	sectionData:      12,
+}
+
+var magic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// DecodeModule decodes the Wasm binary bin.
+func DecodeModule(bin []byte) (*Module, error) {
+	r := &reader{buf: bin}
+	m, err := r.bytes(4)
+	if err != nil || !bytes.Equal(m, magic) {
+		return nil, fmt.Errorf("wasm: invalid magic number")
+	}
+	v, err := r.bytes(4)
+	if err != nil || !bytes.Equal(v, []byte{0x01, 0x00, 0x00, 0x00}) {
+		return nil, fmt.Errorf("wasm: unsupported version")
+	}
+
+	mod := &Module{}
+	var last int
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		content, err := r.vec()
+		if err != nil {
+			return nil, fmt.Errorf("wasm: section %d: %v", id, err)
+		}
+		if id != sectionCustom {
+			order, ok := sectionOrders[id]
+			if !ok {
+				return nil, fmt.Errorf("wasm: unknown section: %d", id)
+			}
+			if order <= last {
+				return nil, fmt.Errorf("wasm: section %d is duplicated or out of order", id)
+			}
+			last = order
+		}
+		sr := &reader{buf: content}
+		if err := mod.decodeSection(id, sr); err != nil {
+			return nil, fmt.Errorf("wasm: section %d: %v", id, err)
+		}
+		if id != sectionCustom && !sr.eof() {
+			return nil, fmt.Errorf("wasm: section %d: section size mismatch", id)
+		}
+	}
+
+	if len(mod.Functions) != len(mod.Code) {
+		return nil, fmt.Errorf("wasm: the numbers of the functions and the function bodies mismatch: %d vs %d", len(mod.Functions), len(mod.Code))
+	}
+	for i, t := range mod.Functions {
+		if int(t) >= len(mod.Types) {
+			return nil, fmt.Errorf("wasm: type index of function %d out of range: %d", i, t)
+		}
+	}
+	for i, e := range mod.Imports {
+		if e.Kind == ExternalFunction && int(e.TypeIndex) >= len(mod.Types) {
+			return nil, fmt.Errorf("wasm: type index of import %d out of range: %d", i, e.TypeIndex)
+		}
+	}
+	var numFuncs int
+	for _, e := range mod.Imports {
+		if e.Kind == ExternalFunction {
+			numFuncs++
+		}
+	}
+	numFuncs += len(mod.Functions)
+	for _, e := range mod.Exports {
+		if e.Kind == ExternalFunction && int(e.Index) >= numFuncs {
+			return nil, fmt.Errorf("wasm: function index of export %q out of range: %d", e.FieldStr, e.Index)
+		}
+	}
+	for i, e := range mod.Elements {
+		for _, idx := range e.Elems {
+			if int(idx) >= numFuncs {
+				return nil, fmt.Errorf("wasm: function index of element %d out of range: %d", i, idx)
+			}
+		}
+	}
+	if mod.Start != nil && int(*mod.Start) >= numFuncs {
+		return nil, fmt.Errorf("wasm: start function index out of range: %d", *mod.Start)
+	}
+	return mod, nil
+}
+
+// count reads the number of the entries of a vector.
+func (r *reader) count() (int, error) {
+	n, err := r.u32()
+	if err != nil {
+		return 0, err
+	}
+	// Each entry has at least one byte.
+	if int(n) > len(r.buf)-r.pos {
+		return 0, fmt.Errorf("too many entries: %d", n)
+	}
+	return int(n), nil
+}
+
+func (m *Module) decodeSection(id byte, r *reader) error {
+	switch id {
+	case sectionCustom:
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		m.Customs = append(m.Customs, CustomSection{
+			Name: name,
+			Data: r.buf[r.pos:],
+		})
+		r.pos = len(r.buf)
+		return nil
+
+	case sectionType:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			form, err := r.byte()
+			if err != nil {
+				return err
+			}
+			if form != 0x60 {
+				return fmt.Errorf("invalid function type form: 0x%02x", form)
+			}
+			params, err := r.valueTypes()
+			if err != nil {
+				return err
+			}
+			results, err := r.valueTypes()
+			if err != nil {
+				return err
+			}
+			m.Types = append(m.Types, FunctionSig{
+				ParamTypes:  params,
+				ReturnTypes: results,
+			})
+		}
+		return nil
+
+	case sectionImport:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			mod, err := r.name()
+			if err != nil {
+				return err
+			}
+			name, err := r.name()
+			if err != nil {
+				return err
+			}
+			kind, err := r.byte()
+			if err != nil {
+				return err
+			}
+			e := Import{
+				ModuleName: mod,
+				FieldName:  name,
+				Kind:       External(kind),
+			}
+			switch e.Kind {
+			case ExternalFunction:
+				t, err := r.u32()
+				if err != nil {
+					return err
+				}
+				e.TypeIndex = t
+			case ExternalTable:
+				if _, err := r.table(); err != nil {
+					return err
+				}
+			case ExternalMemory:
+				if _, err := r.limits(); err != nil {
+					return err
+				}
+			case ExternalGlobal:
+				if _, err := r.globalType(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("invalid import kind: %d", kind)
+			}
+			m.Imports = append(m.Imports, e)
+		}
+		return nil
+
+	case sectionFunction:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			t, err := r.u32()
+			if err != nil {
+				return err
+			}
+			m.Functions = append(m.Functions, t)
+		}
+		return nil
+
+	case sectionTable:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			t, err := r.table()
+			if err != nil {
+				return err
+			}
+			m.Tables = append(m.Tables, t)
+		}
+		return nil
+
+	case sectionMemory:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			l, err := r.limits()
+			if err != nil {
+				return err
+			}
+			m.Memories = append(m.Memories, Memory{Limits: l})
+		}
+		return nil
+
+	case sectionGlobal:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			t, err := r.globalType()
+			if err != nil {
+				return err
+			}
+			init, err := r.initExpr()
+			if err != nil {
+				return err
+			}
+			m.Globals = append(m.Globals, Global{
+				Type: t,
+				Init: init,
+			})
+		}
+		return nil
+
+	case sectionExport:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			name, err := r.name()
+			if err != nil {
+				return err
+			}
+			kind, err := r.byte()
+			if err != nil {
+				return err
+			}
+			if kind > byte(ExternalGlobal) {
+				return fmt.Errorf("invalid export kind: %d", kind)
+			}
+			idx, err := r.u32()
+			if err != nil {
+				return err
+			}
+			m.Exports = append(m.Exports, Export{
+				FieldStr: name,
+				Kind:     External(kind),
+				Index:    idx,
+			})
+		}
+		return nil
+
+	case sectionStart:
+		idx, err := r.u32()
+		if err != nil {
+			return err
+		}
+		m.Start = &idx
+		return nil
+
+	case sectionElement:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			flags, err := r.u32()
+			if err != nil {
+				return err
+			}
+			if flags != 0 {
+				return fmt.Errorf("element segment with flags %d is not implemented", flags)
+			}
+			offset, err := r.initExpr()
+			if err != nil {
+				return err
+			}
+			num, err := r.count()
+			if err != nil {
+				return err
+			}
+			elems := make([]uint32, num)
+			for j := range elems {
+				v, err := r.u32()
+				if err != nil {
+					return err
+				}
+				elems[j] = v
+			}
+			m.Elements = append(m.Elements, Element{
+				Offset: offset,
+				Elems:  elems,
+			})
+		}
+		return nil
+
+	case sectionDataCount:
+		// The data count is used only for validation.
+		_, err := r.u32()
+		return err
+
+	case sectionCode:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			b, err := r.vec()
+			if err != nil {
+				return err
+			}
+			body, err := decodeFunctionBody(b)
+			if err != nil {
+				return fmt.Errorf("function body %d: %v", i, err)
+			}
+			m.Code = append(m.Code, body)
+		}
+		return nil
+
+	case sectionData:
+		n, err := r.count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			flags, err := r.u32()
+			if err != nil {
+				return err
+			}
+			if flags != 0 {
+				return fmt.Errorf("data segment with flags %d is not implemented", flags)
+			}
+			offset, err := r.initExpr()
+			if err != nil {
+				return err
+			}
+			data, err := r.vec()
+			if err != nil {
+				return err
+			}
+			m.Data = append(m.Data, Data{
+				Offset: offset,
+				Data:   data,
+			})
+		}
+		return nil
+	}
+	panic("not reached")
+}
+
+func decodeFunctionBody(b []byte) (FunctionBody, error) {
+	r := &reader{buf: b}
+	n, err := r.count()
+	if err != nil {
+		return FunctionBody{}, err
+	}
+	var body FunctionBody
+	var total uint64
+	for i := 0; i < n; i++ {
+		c, err := r.u32()
+		if err != nil {
+			return FunctionBody{}, err
+		}
+		total += uint64(c)
+		if total > maxLocals {
+			return FunctionBody{}, fmt.Errorf("too many locals: more than %d", maxLocals)
+		}
+		t, err := r.valueType()
+		if err != nil {
+			return FunctionBody{}, err
+		}
+		body.Locals = append(body.Locals, LocalEntry{
+			Count: c,
+			Type:  t,
+		})
+	}
+	code := r.buf[r.pos:]
+	if len(code) == 0 || code[len(code)-1] != byte(OpEnd) {
+		return FunctionBody{}, fmt.Errorf("function body must end with end")
+	}
+	body.Code = code[:len(code)-1]
+	return body, nil
+}
+
+func (r *reader) valueType() (ValueType, error) {
+	b, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch t := ValueType(b); t {
+	case ValueTypeI32, ValueTypeI64, ValueTypeF32, ValueTypeF64:
+		return t, nil
+	}
+	return 0, fmt.Errorf("invalid value type: 0x%02x", b)
+}
+
+func (r *reader) valueTypes() ([]ValueType, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	var ts []ValueType
+	for i := 0; i < n; i++ {
+		t, err := r.valueType()
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+func (r *reader) limits() (Limits, error) {
+	flags, err := r.byte()
+	if err != nil {
+		return Limits{}, err
+	}
+	if flags > 1 {
+		return Limits{}, fmt.Errorf("invalid limits flags: %d", flags)
+	}
+	var l Limits
+	l.Initial, err = r.u32()
+	if err != nil {
+		return Limits{}, err
+	}
+	if flags == 1 {
+		l.HasMaximum = true
+		l.Maximum, err = r.u32()
+		if err != nil {
+			return Limits{}, err
+		}
+	}
+	return l, nil
+}
+
+func (r *reader) table() (Table, error) {
+	t, err := r.byte()
+	if err != nil {
+		return Table{}, err
+	}
+	// Only funcref is supported.
+	if t != 0x70 {
+		return Table{}, fmt.Errorf("invalid element type: 0x%02x", t)
+	}
+	l, err := r.limits()
+	if err != nil {
+		return Table{}, err
+	}
+	return Table{
+		ElemType: t,
+		Limits:   l,
+	}, nil
+}
+
+func (r *reader) globalType() (GlobalType, error) {
+	t, err := r.valueType()
+	if err != nil {
+		return GlobalType{}, err
+	}
+	mut, err := r.byte()
+	if err != nil {
+		return GlobalType{}, err
+	}
+	if mut > 1 {
+		return GlobalType{}, fmt.Errorf("invalid mutability: %d", mut)
+	}
+	return GlobalType{
+		Type:    t,
+		Mutable: mut == 1,
+	}, nil
+}
+
+// initExpr reads a constant expression and returns the bytes including the last end.
+func (r *reader) initExpr() ([]byte, error) {
+	start := r.pos
+	for {
+		instr, err := r.instr()
+		if err != nil {
+			return nil, err
+		}
+		if instr.Op == OpEnd {
+			return r.buf[start:r.pos], nil
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasm"
+	"github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+// testModule imports env.f of (i32) -> (), and defines main.g of () -> (i32, i64) that is exported as g.
+var testModule = wasmtest.Module(
+	wasmtest.Section(wasmtest.SectionType, 0x02, 0x60, 0x01, 0x7f, 0x00, 0x60, 0x00, 0x02, 0x7f, 0x7e),
+	wasmtest.Section(wasmtest.SectionImport, 0x01, 0x03, 'e', 'n', 'v', 0x01, 'f', 0x00, 0x00),
+	wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x01),
+	wasmtest.Section(wasmtest.SectionMemory, 0x01, 0x01, 0x01, 0x02),
+	wasmtest.Section(wasmtest.SectionGlobal, 0x01, 0x7f, 0x01, 0x41, 0x2a, 0x0b),
+	wasmtest.Section(wasmtest.SectionExport, 0x02, 0x01, 'g', 0x00, 0x01, 0x03, 'm', 'e', 'm', 0x02, 0x00),
+	wasmtest.Section(wasmtest.SectionCode, 0x01, 0x08, 0x01, 0x02, 0x7c, 0x41, 0x01, 0x42, 0x02, 0x0b),
+	wasmtest.Section(wasmtest.SectionData, 0x01, 0x00, 0x41, 0x08, 0x0b, 0x02, 'h', 'i'),
+	wasmtest.Section(wasmtest.SectionCustom, 0x04, 'n', 'a', 'm', 'e',
+		0x00, 0x02, 0x01, 'm',
+		0x01, 0x10, 0x02, 0x00, 0x05, 'e', 'n', 'v', '.', 'f', 0x01, 0x06, 'm', 'a', 'i', 'n', '.', 'g'),
+)
+
+func TestDecodeModule(t *testing.T) {
+	m, err := DecodeModule(testModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := m.Types, []FunctionSig{
+		{ParamTypes: []ValueType{ValueTypeI32}},
+		{ReturnTypes: []ValueType{ValueTypeI32, ValueTypeI64}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("types: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Imports, []Import{
+		{ModuleName: "env", FieldName: "f", Kind: ExternalFunction, TypeIndex: 0},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("imports: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Functions, []uint32{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("functions: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Memories, []Memory{
+		{Limits: Limits{Initial: 1, Maximum: 2, HasMaximum: true}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("memories: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Globals, []Global{
+		{Type: GlobalType{Type: ValueTypeI32, Mutable: true}, Init: []byte{0x41, 0x2a, 0x0b}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("globals: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Exports, []Export{
+		{FieldStr: "g", Kind: ExternalFunction, Index: 1},
+		{FieldStr: "mem", Kind: ExternalMemory, Index: 0},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("exports: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Code, []FunctionBody{
+		{Locals: []LocalEntry{{Count: 2, Type: ValueTypeF64}}, Code: []byte{0x41, 0x01, 0x42, 0x02}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("code: got: %v, want: %v", got, want)
+	}
+	if got, want := m.Data, []Data{
+		{Offset: []byte{0x41, 0x08, 0x0b}, Data: []byte("hi")},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("data: got: %v, want: %v", got, want)
+	}
+	if m.Start != nil {
+		t.Errorf("start: got: %d, want: nil", *m.Start)
+	}
+	if m.Custom("producers") != nil {
+		t.Errorf("Custom(%q) must be nil", "producers")
+	}
+
+	names, err := m.FunctionNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names, map[uint32]string{0: "env.f", 1: "main.g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("function names: got: %v, want: %v", got, want)
+	}
+}
+
+func TestDecodeModuleError(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Bin  []byte
+	}{
+		{
+			Name: "magic",
+			Bin:  []byte{0x00, 0x61, 0x73, 0x6e, 0x01, 0x00, 0x00, 0x00},
+		},
+		{
+			Name: "version",
+			Bin:  []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00},
+		},
+		{
+			Name: "section order",
+			Bin:  wasmtest.Module(wasmtest.Section(wasmtest.SectionFunction, 0x00), wasmtest.Section(wasmtest.SectionType, 0x00)),
+		},
+		{
+			Name: "section size",
+			Bin:  wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x00, 0x00)),
+		},
+		{
+			Name: "truncated section",
+			Bin:  wasmtest.Module([]byte{0x01, 0x05, 0x00}),
+		},
+		{
+			Name: "function and code mismatch",
+			Bin:  wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x01, 0x60, 0x00, 0x00), wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00)),
+		},
+		{
+			Name: "type index",
+			Bin:  wasmtest.Module(wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00), wasmtest.Section(wasmtest.SectionCode, 0x01, 0x02, 0x00, 0x0b)),
+		},
+		{
+			Name: "function body without end",
+			Bin:  wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x01, 0x60, 0x00, 0x00), wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00), wasmtest.Section(wasmtest.SectionCode, 0x01, 0x02, 0x00, 0x01)),
+		},
+		{
+			Name: "export function index",
+			Bin: wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x01, 0x60, 0x00, 0x00), wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00),
+				wasmtest.Section(wasmtest.SectionExport, 0x01, 0x01, 'f', 0x00, 0x01), wasmtest.Section(wasmtest.SectionCode, 0x01, 0x02, 0x00, 0x0b)),
+		},
+		{
+			Name: "element function index",
+			Bin: wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x01, 0x60, 0x00, 0x00), wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00),
+				wasmtest.Section(wasmtest.SectionTable, 0x01, 0x70, 0x00, 0x01),
+				wasmtest.Section(wasmtest.SectionElement, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x01, 0x01), wasmtest.Section(wasmtest.SectionCode, 0x01, 0x02, 0x00, 0x0b)),
+		},
+		{
+			Name: "start function index",
+			Bin: wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x01, 0x60, 0x00, 0x00), wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00),
+				wasmtest.Section(wasmtest.SectionStart, 0x01), wasmtest.Section(wasmtest.SectionCode, 0x01, 0x02, 0x00, 0x0b)),
+		},
+		{
+			// 2 entries of 0x7fffffff i32 locals.
+			Name: "too many locals",
+			Bin: wasmtest.Module(wasmtest.Section(wasmtest.SectionType, 0x01, 0x60, 0x00, 0x00), wasmtest.Section(wasmtest.SectionFunction, 0x01, 0x00),
+				wasmtest.Section(wasmtest.SectionCode, 0x01, 0x0e, 0x02, 0xff, 0xff, 0xff, 0xff, 0x07, 0x7f, 0xff, 0xff, 0xff, 0xff, 0x07, 0x7f, 0x0b)),
+		},
+	}
+	for _, tc := range testCases {
+		if _, err := DecodeModule(tc.Bin); err == nil {
+			t.Errorf("%s: DecodeModule must fail", tc.Name)
+		}
+	}
+}
+
+func TestFunctionNamesWithoutNameSection(t *testing.T) {
+	m, err := DecodeModule(wasmtest.Module())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := m.FunctionNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names != nil {
+		t.Errorf("got: %v, want: nil", names)
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm
+
+import (
+	"fmt"
+)
+
+func (o Opcode) String() string {
+	if n, ok := opcodeNames[o]; ok {
+		return n
+	}
+	if o>>8 == prefixFC {
+		return fmt.Sprintf("0x%02x %d", prefixFC, o&0xff)
+	}
+	return fmt.Sprintf("0x%02x", uint16(o))
+}
+
+// Instr is a decoded instruction.
+//
+// The types of Immediates are:
+//
+//   - block, loop, if: int64 of the block type. A negative value is the empty type or a value type, and a non-negative
+//     value is a type index.
+//   - br, br_if, call, local.*, global.*: uint32.
+//   - br_table: uint32 of the number of the targets, the targets and the default target.
+//   - call_indirect: uint32 of the type index and uint32 of the table index.
+//   - memory.size, memory.grow: byte of the memory index.
+//   - load and store: uint32 of the alignment and uint32 of the offset.
+//   - i32.const: int32, i64.const: int64, f32.const: float32, f64.const: float64.
+//   - memory.copy: bytes of the destination and the source memory indices, memory.fill: byte of the memory index.
+type Instr struct {
+	Op         Opcode
+	Immediates []interface{}
+}
+
+// Disassemble decodes the instructions of a function body or a constant expression.
+func Disassemble(code []byte) ([]Instr, error) {
+	r := &reader{buf: code}
+	var instrs []Instr
+	for !r.eof() {
+		instr, err := r.instr()
+		if err != nil {
+			return nil, err
+		}
+		instrs = append(instrs, instr)
+	}
+	return instrs, nil
+}
+
+func (r *reader) memIndex() (byte, error) {
+	m, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	if m != 0 {
+		return 0, fmt.Errorf("wasm: memory index must be 0")
+	}
+	return m, nil
+}
+
+func (r *reader) instr() (Instr, error) {
+	b, err := r.byte()
+	if err != nil {
+		return Instr{}, err
+	}
+	op := Opcode(b)
+	if b == prefixFC {
+		sub, err := r.u32()
+		if err != nil {
+			return Instr{}, err
+		}
+		if sub > 0xff {
+			return Instr{}, fmt.Errorf("wasm: unknown opcode: 0x%02x %d", b, sub)
+		}
+		op = prefixFC<<8 | Opcode(sub)
+	}
+	if _, ok := opcodeNames[op]; !ok {
+		return Instr{}, fmt.Errorf("wasm: unknown opcode: %s", op)
+	}
+
+	instr := Instr{
+		Op: op,
+	}
+	add := func(v interface{}) {
+		instr.Immediates = append(instr.Immediates, v)
+	}
+
+	switch op {
+	case OpBlock, OpLoop, OpIf:
+		t, err := r.varint(33)
+		if err != nil {
+			return Instr{}, err
+		}
+		add(t)
+	case OpBr, OpBrIf, OpCall, OpGetLocal, OpSetLocal, OpTeeLocal, OpGetGlobal, OpSetGlobal:
+		v, err := r.u32()
+		if err != nil {
+			return Instr{}, err
+		}
+		add(v)
+	case OpBrTable:
+		n, err := r.u32()
+		if err != nil {
+			return Instr{}, err
+		}
+		add(n)
+		// The targets and the default target.
+		for i := uint32(0); i < n+1; i++ {
+			v, err := r.u32()
+			if err != nil {
+				return Instr{}, err
+			}
+			add(v)
+		}
+	case OpCallIndirect:
+		v, err := r.u32()
+		if err != nil {
+			return Instr{}, err
+		}
+		t, err := r.byte()
+		if err != nil {
+			return Instr{}, err
+		}
+		if t != 0 {
+			return Instr{}, fmt.Errorf("wasm: table index in call_indirect must be 0")
+		}
+		add(v)
+		add(uint32(t))
+	case OpCurrentMemory, OpGrowMemory, OpMemoryFill:
+		m, err := r.memIndex()
+		if err != nil {
+			return Instr{}, err
+		}
+		add(m)
+	case OpMemoryCopy:
+		for i := 0; i < 2; i++ {
+			m, err := r.memIndex()
+			if err != nil {
+				return Instr{}, err
+			}
+			add(m)
+		}
+	case OpI32Const:
+		v, err := r.varint(32)
+		if err != nil {
+			return Instr{}, err
+		}
+		add(int32(v))
+	case OpI64Const:
+		v, err := r.varint(64)
+		if err != nil {
+			return Instr{}, err
+		}
+		add(v)
+	case OpF32Const:
+		v, err := r.f32()
+		if err != nil {
+			return Instr{}, err
+		}
+		add(v)
+	case OpF64Const:
+		v, err := r.f64()
+		if err != nil {
+			return Instr{}, err
+		}
+		add(v)
+	default:
+		if OpI32Load <= op && op <= OpI64Store32 {
+			align, err := r.u32()
+			if err != nil {
+				return Instr{}, err
+			}
+			offset, err := r.u32()
+			if err != nil {
+				return Instr{}, err
+			}
+			add(align)
+			add(offset)
+		}
+	}
+	return instr, nil
+}
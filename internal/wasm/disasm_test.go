@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasm"
+)
+
+func TestDisassemble(t *testing.T) {
+	testCases := []struct {
+		Name       string
+		Code       []byte
+		Ops        []Opcode
+		Immediates [][]interface{}
+	}{
+		{
+			Name:       "sign extension",
+			Code:       []byte{0x20, 0x00, 0xc0, 0xc4},
+			Ops:        []Opcode{OpGetLocal, OpI32Extend8S, OpI64Extend32S},
+			Immediates: [][]interface{}{{uint32(0)}, nil, nil},
+		},
+		{
+			Name:       "saturating truncation",
+			Code:       []byte{0x20, 0x00, 0xfc, 0x00, 0xfc, 0x07},
+			Ops:        []Opcode{OpGetLocal, OpI32TruncSatF32S, OpI64TruncSatF64U},
+			Immediates: [][]interface{}{{uint32(0)}, nil, nil},
+		},
+		{
+			Name:       "bulk memory",
+			Code:       []byte{0xfc, 0x0a, 0x00, 0x00, 0xfc, 0x0b, 0x00},
+			Ops:        []Opcode{OpMemoryCopy, OpMemoryFill},
+			Immediates: [][]interface{}{{byte(0), byte(0)}, {byte(0)}},
+		},
+		{
+			Name:       "block types",
+			Code:       []byte{0x02, 0x40, 0x03, 0x7f, 0x04, 0xc0, 0x00, 0x0b, 0x0b, 0x0b},
+			Ops:        []Opcode{OpBlock, OpLoop, OpIf, OpEnd, OpEnd, OpEnd},
+			Immediates: [][]interface{}{{int64(-64)}, {int64(-1)}, {int64(64)}, nil, nil, nil},
+		},
+		{
+			Name:       "br_table",
+			Code:       []byte{0x0e, 0x02, 0x00, 0x01, 0x80, 0x01},
+			Ops:        []Opcode{OpBrTable},
+			Immediates: [][]interface{}{{uint32(2), uint32(0), uint32(1), uint32(128)}},
+		},
+		{
+			Name:       "memory",
+			Code:       []byte{0x41, 0x7f, 0x28, 0x02, 0x08, 0x3f, 0x00},
+			Ops:        []Opcode{OpI32Const, OpI32Load, OpCurrentMemory},
+			Immediates: [][]interface{}{{int32(-1)}, {uint32(2), uint32(8)}, {byte(0)}},
+		},
+	}
+	for _, tc := range testCases {
+		instrs, err := Disassemble(tc.Code)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		var ops []Opcode
+		var imms [][]interface{}
+		for _, instr := range instrs {
+			ops = append(ops, instr.Op)
+			imms = append(imms, instr.Immediates)
+		}
+		if !reflect.DeepEqual(ops, tc.Ops) {
+			t.Errorf("%s: ops: got: %#v, want: %#v", tc.Name, ops, tc.Ops)
+		}
+		if !reflect.DeepEqual(imms, tc.Immediates) {
+			t.Errorf("%s: immediates: got: %#v, want: %#v", tc.Name, imms, tc.Immediates)
+		}
+	}
+
+	for _, code := range [][]byte{{0xc5}, {0xfc, 0x7f}} {
+		if _, err := Disassemble(code); err == nil {
+			t.Errorf("Disassemble(%#v) must fail with an unknown opcode", code)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package wasm decodes WebAssembly binary modules.
+//
+// See https://webassembly.github.io/spec/core/binary/index.html
+package wasm
+
+type ValueType byte
+
+const (
+	ValueTypeI32 ValueType = 0x7f
+	ValueTypeI64 ValueType = 0x7e
+	ValueTypeF32 ValueType = 0x7d
+	ValueTypeF64 ValueType = 0x7c
+)
+
+// BlockTypeEmpty is the block type of a block without results.
+const BlockTypeEmpty = 0x40
+
+// External is a kind of imports and exports.
+type External byte
+
+const (
+	ExternalFunction External = 0
+	ExternalTable    External = 1
+	ExternalMemory   External = 2
+	ExternalGlobal   External = 3
+)
+
+type FunctionSig struct {
+	ParamTypes  []ValueType
+	ReturnTypes []ValueType
+}
+
+// Function is a function with its signature, body and name.
+type Function struct {
+	Sig  *FunctionSig
+	Body *FunctionBody
+	Name string
+}
+
+type LocalEntry struct {
+	Count uint32
+	Type  ValueType
+}
+
+type FunctionBody struct {
+	Locals []LocalEntry
+
+	// Code is the instructions without the last end.
+	Code []byte
+}
+
+type Import struct {
+	ModuleName string
+	FieldName  string
+	Kind       External
+
+	// TypeIndex is the index of the function type. TypeIndex is valid only when Kind is ExternalFunction.
+	TypeIndex uint32
+}
+
+type Export struct {
+	FieldStr string
+	Kind     External
+	Index    uint32
+}
+
+type Limits struct {
+	Initial    uint32
+	Maximum    uint32
+	HasMaximum bool
+}
+
+type Table struct {
+	ElemType byte
+	Limits   Limits
+}
+
+type Memory struct {
+	Limits Limits
+}
+
+type GlobalType struct {
+	Type    ValueType
+	Mutable bool
+}
+
+type Global struct {
+	Type GlobalType
+
+	// Init is the initializer expression including the last end.
+	Init []byte
+}
+
+type Element struct {
+	Index uint32
+
+	// Offset is the offset expression including the last end.
+	Offset []byte
+	Elems  []uint32
+}
+
+type Data struct {
+	// Offset is the offset expression including the last end.
+	Offset []byte
+	Data   []byte
+}
+
+type CustomSection struct {
+	Name string
+	Data []byte
+}
+
+// Module is a decoded Wasm module.
+//
+// The indices of Functions and Code correspond to each other. Neither includes the imported functions.
+type Module struct {
+	Types     []FunctionSig
+	Imports   []Import
+	Functions []uint32
+	Tables    []Table
+	Memories  []Memory
+	Globals   []Global
+	Exports   []Export
+	Start     *uint32
+	Elements  []Element
+	Code      []FunctionBody
+	Data      []Data
+	Customs   []CustomSection
+}
+
+// Custom returns the first custom section named name, or nil if there is no such section.
+func (m *Module) Custom(name string) *CustomSection {
+	for i := range m.Customs {
+		if m.Customs[i].Name == name {
+			return &m.Customs[i]
+		}
+	}
+	return nil
+}
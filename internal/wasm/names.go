@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm
+
+import (
+	"fmt"
+)
+
+// nameFunction is the ID of the function names subsection in the name section.
+const nameFunction = 1
+
+// FunctionNames returns the function names in the name section, keyed by the function indices including the imported
+// functions. FunctionNames returns nil if there is no name section.
+//
+// See https://webassembly.github.io/spec/core/appendix/custom.html#name-section
+func (m *Module) FunctionNames() (map[uint32]string, error) {
+	c := m.Custom("name")
+	if c == nil {
+		return nil, nil
+	}
+
+	r := &reader{buf: c.Data}
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		content, err := r.vec()
+		if err != nil {
+			return nil, fmt.Errorf("wasm: name subsection %d: %v", id, err)
+		}
+		if id != nameFunction {
+			continue
+		}
+
+		sr := &reader{buf: content}
+		n, err := sr.count()
+		if err != nil {
+			return nil, fmt.Errorf("wasm: function names: %v", err)
+		}
+		names := make(map[uint32]string, n)
+		for i := 0; i < n; i++ {
+			idx, err := sr.u32()
+			if err != nil {
+				return nil, fmt.Errorf("wasm: function names: %v", err)
+			}
+			name, err := sr.name()
+			if err != nil {
+				return nil, fmt.Errorf("wasm: function names: %v", err)
+			}
+			names[idx] = name
+		}
+		return names, nil
+	}
+	return nil, nil
+}
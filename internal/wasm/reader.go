@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// reader reads the primitive values of the binary format.
+type reader struct {
+	buf []byte
+	pos int
+}
+
+func (r *reader) eof() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.eof() {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || len(r.buf)-r.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// u32 reads an unsigned LEB128 integer of 32 bits.
+func (r *reader) u32() (uint32, error) {
+	var x uint64
+	var shift uint
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= 35 {
+			return 0, fmt.Errorf("wasm: too long LEB128 integer")
+		}
+		x |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if x > math.MaxUint32 {
+		return 0, fmt.Errorf("wasm: too big LEB128 integer: %d", x)
+	}
+	return uint32(x), nil
+}
+
+// varint reads a signed LEB128 integer of the given bit size.
+func (r *reader) varint(size uint) (int64, error) {
+	var x int64
+	var shift uint
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= size {
+			return 0, fmt.Errorf("wasm: too long LEB128 integer")
+		}
+		x |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				x |= -1 << shift
+			}
+			return x, nil
+		}
+	}
+}
+
+func (r *reader) f32() (float32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (r *reader) f64() (float64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// vec reads a length-prefixed byte sequence.
+func (r *reader) vec() ([]byte, error) {
+	n, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	return r.bytes(int(n))
+}
+
+func (r *reader) name() (string, error) {
+	b, err := r.vec()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package wasmtest provides functions to build Wasm binaries for tests.
+//
+// The functions return the encoded bytes as they are, so that a test can combine them with hand-written bytes, e.g.,
+// a malformed section.
+package wasmtest
+
+const (
+	SectionCustom   = 0
+	SectionType     = 1
+	SectionImport   = 2
+	SectionFunction = 3
+	SectionTable    = 4
+	SectionMemory   = 5
+	SectionGlobal   = 6
+	SectionExport   = 7
+	SectionStart    = 8
+	SectionElement  = 9
+	SectionCode     = 10
+	SectionData     = 11
+)
+
+const (
+	I32 = 0x7f
+	I64 = 0x7e
+	F32 = 0x7d
+	F64 = 0x7c
+)
+
+const (
+	KindFunction = 0x00
+	KindTable    = 0x01
+	KindMemory   = 0x02
+	KindGlobal   = 0x03
+)
+
+// Module returns a Wasm binary that has the header and sections.
+func Module(sections ...[]byte) []byte {
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	for _, s := range sections {
+		b = append(b, s...)
+	}
+	return b
+}
+
+// Section returns a section of id with content.
+func Section(id byte, content ...byte) []byte {
+	b := append([]byte{id}, U32(uint32(len(content)))...)
+	return append(b, content...)
+}
+
+// U32 returns the unsigned LEB128 encoding of v.
+func U32(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// I32Const returns the signed LEB128 encoding of v.
+func I32Const(v int32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// Vec returns a vector of items.
+func Vec(items ...[]byte) []byte {
+	b := U32(uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+// Name returns a name, i.e., a vector of the bytes of s.
+func Name(s string) []byte {
+	return append(U32(uint32(len(s))), s...)
+}
+
+// FuncType returns a function type of params and results. params and results are value types like I32.
+func FuncType(params, results []byte) []byte {
+	b := append([]byte{0x60}, U32(uint32(len(params)))...)
+	b = append(b, params...)
+	b = append(b, U32(uint32(len(results)))...)
+	return append(b, results...)
+}
+
+// ImportFunc returns an import of the function module.name of the type at typeIndex.
+func ImportFunc(module, name string, typeIndex uint32) []byte {
+	b := append(Name(module), Name(name)...)
+	b = append(b, KindFunction)
+	return append(b, U32(typeIndex)...)
+}
+
+// Export returns an export of the item of kind at index as name.
+func Export(name string, kind byte, index uint32) []byte {
+	b := append(Name(name), kind)
+	return append(b, U32(index)...)
+}
+
+// Limits returns limits without the maximum.
+func Limits(min uint32) []byte {
+	return append([]byte{0x00}, U32(min)...)
+}
+
+// Table returns a funcref table of min elements without the maximum.
+func Table(min uint32) []byte {
+	return append([]byte{0x70}, Limits(min)...)
+}
+
+// Element returns an active element segment for table 0 at offset with the function indices.
+func Element(offset int32, funcs ...uint32) []byte {
+	b := append([]byte{0x00, 0x41}, I32Const(offset)...)
+	b = append(b, 0x0b)
+	var idxs [][]byte
+	for _, f := range funcs {
+		idxs = append(idxs, U32(f))
+	}
+	return append(b, Vec(idxs...)...)
+}
+
+// Body returns a function body without locals. code doesn't include the last end.
+func Body(code ...byte) []byte {
+	b := append([]byte{0x00}, code...)
+	b = append(b, 0x0b)
+	return append(U32(uint32(len(b))), b...)
+}
+
+// Data returns an active data segment for memory 0 at offset.
+func Data(offset int32, data []byte) []byte {
+	b := append([]byte{0x00, 0x41}, I32Const(offset)...)
+	b = append(b, 0x0b)
+	return append(b, Name(string(data))...)
+}
+
+// Names returns a name section that has the function names. The i-th name is for the function at index i.
+func Names(funcNames ...string) []byte {
+	var names [][]byte
+	for i, n := range funcNames {
+		names = append(names, append(U32(uint32(i)), Name(n)...))
+	}
+	sub := Vec(names...)
+	b := append(Name("name"), 0x01)
+	b = append(b, U32(uint32(len(sub)))...)
+	b = append(b, sub...)
+	return Section(SectionCustom, b...)
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wasmtest_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/hajimehoshi/go2cpp/internal/wasmtest"
+)
+
+func TestLEB128(t *testing.T) {
+	testCases := []struct {
+		Got  []byte
+		Want []byte
+	}{
+		{U32(0), []byte{0x00}},
+		{U32(127), []byte{0x7f}},
+		{U32(128), []byte{0x80, 0x01}},
+		{U32(0xffffffff), []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{I32Const(0), []byte{0x00}},
+		{I32Const(63), []byte{0x3f}},
+		{I32Const(64), []byte{0xc0, 0x00}},
+		{I32Const(-1), []byte{0x7f}},
+		{I32Const(-65), []byte{0xbf, 0x7f}},
+	}
+	for _, tc := range testCases {
+		if !bytes.Equal(tc.Got, tc.Want) {
+			t.Errorf("got: % x, want: % x", tc.Got, tc.Want)
+		}
+	}
+}
+
+func TestSectionSize(t *testing.T) {
+	s := Section(SectionCustom, make([]byte, 200)...)
+	if got, want := s[:3], []byte{SectionCustom, 0xc8, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("got: % x, want: % x", got, want)
+	}
+	if got, want := len(s), 203; got != want {
+		t.Errorf("len(s): got: %d, want: %d", got, want)
+	}
+}
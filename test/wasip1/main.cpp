@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: Apache-2.0
+
+#include <string>
+#include <vector>
+
+#include "autogen/go.h"
+
+// usage: test dir [args]
+//
+// dir is preopened as / for the program.
+int main(int argc, char *argv[]) {
+  go2cpp_autogen::Go go;
+  go.SetEnv({"GO2CPP_TEST=1"});
+  go.PreopenDir("/", argv[1]);
+  std::vector<std::string> args{"wasip1"};
+  args.insert(args.end(), argv + 2, argv + argc);
+  return go.Run(args);
+}
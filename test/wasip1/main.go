@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// +build wasip1
+
+// This program tests the WASI functions of the generated C++ files. run.sh runs this with the arguments foo and bar,
+// the environment variable GO2CPP_TEST=1, and the standard input "hello from stdin". The directory root is preopened
+// as /, and has symbolic links to the outside of it.
+//
+// This program prints PASS and exits with the code 3 when all the checks pass.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+var failed bool
+
+func check(name string, err error) {
+	if err != nil {
+		fmt.Printf("FAIL: %s: %v\n", name, err)
+		failed = true
+	}
+}
+
+func checkArgs() error {
+	if got, want := strings.Join(os.Args[1:], " "), "foo bar"; got != want {
+		return fmt.Errorf("got: %q, want: %q", got, want)
+	}
+	return nil
+}
+
+func checkEnv() error {
+	if got, want := os.Getenv("GO2CPP_TEST"), "1"; got != want {
+		return fmt.Errorf("got: %q, want: %q", got, want)
+	}
+	return nil
+}
+
+func checkStdin() error {
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if got, want := string(b), "hello from stdin\n"; got != want {
+		return fmt.Errorf("got: %q, want: %q", got, want)
+	}
+	return nil
+}
+
+func checkFiles() error {
+	if err := os.Mkdir("/dir/sub", 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile("/dir/sub/a.txt", []byte("hello"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename("/dir/sub/a.txt", "/dir/sub/b.txt"); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile("/dir/sub/b.txt")
+	if err != nil {
+		return err
+	}
+	if got, want := string(b), "hello"; got != want {
+		return fmt.Errorf("content: got: %q, want: %q", got, want)
+	}
+	fis, err := ioutil.ReadDir("/dir/sub")
+	if err != nil {
+		return err
+	}
+	if len(fis) != 1 || fis[0].Name() != "b.txt" {
+		return fmt.Errorf("ReadDir: got: %v, want: [b.txt]", fis)
+	}
+	if err := os.Remove("/dir/sub/b.txt"); err != nil {
+		return err
+	}
+	if err := os.Remove("/dir/sub"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func checkSymlinks() error {
+	// A symbolic link inside the preopened directory is followed.
+	if err := ioutil.WriteFile("/dir/c.txt", []byte("inside"), 0644); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile("/inner/c.txt")
+	if err != nil {
+		return err
+	}
+	if got, want := string(b), "inside"; got != want {
+		return fmt.Errorf("content: got: %q, want: %q", got, want)
+	}
+
+	// Neither .. nor symbolic links can go out of the preopened directory.
+	for _, path := range []string{"/escape/secret.txt", "/absolute/secret.txt", "/dir/escape/secret.txt", "/../outside/secret.txt"} {
+		if _, err := ioutil.ReadFile(path); err == nil {
+			return fmt.Errorf("%s must not be readable", path)
+		}
+	}
+	return nil
+}
+
+func checkSleep() error {
+	const d = 50 * time.Millisecond
+	start := time.Now()
+	time.Sleep(d)
+	if got := time.Since(start); got < d {
+		return fmt.Errorf("time.Sleep(%v) returned after %v", d, got)
+	}
+	return nil
+}
+
+func main() {
+	check("args", checkArgs())
+	check("env", checkEnv())
+	check("stdin", checkStdin())
+	check("files", checkFiles())
+	check("symlinks", checkSymlinks())
+	check("sleep", checkSleep())
+	if failed {
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+	os.Exit(3)
+}
@@ -0,0 +1,21 @@
+set -e
+echo "# Test wasip1"
+rm -rf autogen root outside
+go run ../../cmd/gowasm2cpp -out autogen -include autogen -pkg . -goos wasip1 -namespace go2cpp_autogen
+clang++ -Wall -std=c++14 -pthread -I. -o test -g *.cpp autogen/*.cpp
+
+mkdir -p root/dir outside
+echo secret > outside/secret.txt
+ln -s dir root/inner
+ln -s ../outside root/escape
+ln -s "$(pwd)/outside" root/absolute
+ln -s ../../outside root/dir/escape
+
+set +e
+echo "hello from stdin" | ./test root foo bar
+code=$?
+set -e
+if [ $code -ne 3 ]; then
+  echo "exit code: $code, want: 3"
+  exit 1
+fi